@@ -1,3 +1,7 @@
 module github.com/quinn-collins/bag-of-words
 
 go 1.25.6
+
+require github.com/quinn-collins/text v0.0.0
+
+replace github.com/quinn-collins/text => ../text
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math"
-	"strings"
+	"log"
+
+	"github.com/quinn-collins/text"
 )
 
 // One-hot encoding is a way of representing categorial values in a numerical way.
@@ -37,74 +39,122 @@ import (
 //   - Slow
 
 func main() {
+	var opts text.TokenizerOptions
+	flag.StringVar(&opts.StopWords, "stopwords", "none", "built-in stop word list to drop: none, english or minimal")
+	flag.StringVar(&opts.StopWordsFile, "stopwords-file", "", "file of extra stop words, one or more per line")
+	flag.BoolVar(&opts.Stem, "stem", false, "reduce words to their Porter2 stem so \"tokenization\" and \"tokenizing\" are one term")
+	wordNGramRange := flag.String("ngrams", "1", "word n-grams to use as features, n or min-max, e.g. 1-2 for words and bigrams")
+	charNGramRange := flag.String("char-ngrams", "", "character n-grams of each word to use instead of word n-grams, n or min-max, e.g. 3-5")
+	corpusPattern := flag.String("corpus", "", "glob of text files to use as the documents instead of the examples, e.g. ../simple-embedding/corpora/*.txt")
+	flag.Parse()
+
+	tokenizer, err := text.NewTokenizer(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ngrams, err := text.NewNGramOptions(*wordNGramRange, *charNGramRange)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	doc1 := "Tokenization is the process of breaking text into words."
 	doc2 := "Vocabulary is the collection of unique words."
 	doc3 := "The process of tokenizing is essential in NLP."
 	corpus := []string{doc1, doc2, doc3}
+	if *corpusPattern != "" {
+		var paths []string
+		paths, corpus, err = text.LoadCorpus(*corpusPattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, path := range paths {
+			fmt.Printf("Document %d: %s\n", i+1, path)
+		}
+	}
 
-	// First we get our list of tokens, in this case that will be space-delimited words in our corpus
-	tokens := tokenize(corpus)
+	// First we get our list of tokens, the words of each document in lower case without punctuation,
+	// so "words." and "words" or "The" and "the" count as the same word.
+	// With -stopwords the common words every document shares are dropped, and with -stem "Tokenization" and
+	// "tokenizing" become the same token, both push the similarities below towards what documents are about.
+	tokens := text.TokenizeAll(corpus, tokenizer)
+
+	// The features are the tokens themselves unless -ngrams or -char-ngrams ask for runs of words or letters,
+	// which keep some of the word order a bag of single words loses: "process of" is a feature of its own.
+	// They are built after -stopwords has dropped its words, so the n-grams join the words that are left.
+	docFeatures := text.Features(tokens, ngrams)
 
 	// Vector size is determined by the set of vocab in the corpus
-	vocab := buildVocab(tokens)
-	fmt.Println(vocab)
+	vocab := text.BuildVocab(docFeatures)
+
+	// A one-hot vector per token is as long as the vocabulary, for a play that is tens of thousands of tokens
+	// times thousands of entries. They are only worth writing out for small examples.
+	oneHots := len(vocab) <= maxPrintedTerms
+	if oneHots {
+		fmt.Println(vocab)
+	} else {
+		fmt.Printf("Vocabulary: %d terms\n", len(vocab))
+	}
 
-	// One-hot encodings for both documents
+	// One-hot encodings for every document
 	// This gives us basis vectors at a token level.
-	fmt.Println("Tokens: ", tokens[0])
-	doc1OneHots := documentToOneHotSequence(tokens[0], vocab)
-	printVectors(doc1OneHots)
-	fmt.Println()
-	fmt.Println("Tokens: ", tokens[1])
-	doc2OneHots := documentToOneHotSequence(tokens[1], vocab)
-	printVectors(doc2OneHots)
-	fmt.Println()
-	fmt.Println("Tokens: ", tokens[2])
-	doc3OneHots := documentToOneHotSequence(tokens[2], vocab)
-	printVectors(doc3OneHots)
-
-	// Bag of words can be computed by summing the vectors.
-	// This lets us generate a vector that represents word frequency in a document.
-	bow1 := bagOfWordsFromOneHots(doc1OneHots, len(vocab))
-	fmt.Println(bow1)
-	bow2 := bagOfWordsFromOneHots(doc2OneHots, len(vocab))
-	fmt.Println(bow2)
-	bow3 := bagOfWordsFromOneHots(doc3OneHots, len(vocab))
+	if oneHots {
+		for _, doc := range docFeatures {
+			fmt.Println("Tokens: ", doc)
+			printVectors(documentToOneHotSequence(doc, vocab))
+			fmt.Println()
+		}
+	}
+
+	// Bag of words can be computed by summing the one-hot vectors.
+	// This lets us generate a vector that represents word frequency in a document. Counting the tokens straight
+	// into a sparse vector gives the same counts while only storing the words the document uses.
+	bows := make([]text.SparseVector, len(docFeatures))
+	for i, doc := range docFeatures {
+		bows[i] = text.SparseCounts(doc, vocab)
+		if oneHots {
+			fmt.Println(bagOfWordsFromOneHots(documentToOneHotSequence(doc, vocab), len(vocab)))
+		}
+	}
 
 	// Once we have bags of words generated per-document we can calculate cosine-similarity between documents.
-	bow1Bow2Similarity := cosineSimilarity(sliceIntToFloat(bow1), sliceIntToFloat(bow2))
-	bow1Bow3Similarity := cosineSimilarity(sliceIntToFloat(bow1), sliceIntToFloat(bow3))
-	bow2Bow3Similarity := cosineSimilarity(sliceIntToFloat(bow2), sliceIntToFloat(bow3))
-	fmt.Println(bow1Bow2Similarity)
-	fmt.Println(bow1Bow3Similarity)
-	fmt.Println(bow2Bow3Similarity)
-}
+	similarities := pairwiseSimilarities(bows)
+	for _, s := range similarities {
+		fmt.Printf("%d-%d: %.4f\n", s.a+1, s.b+1, s.similarity)
+	}
 
-// tokenize returns tokens as space-delimited slices of strings of a corpus.
-func tokenize(corpus []string) [][]string {
-	var tokens [][]string
-	for _, doc := range corpus {
-		tokens = append(tokens, strings.Split(doc, " "))
+	// The same similarities over single words show what the n-grams changed. Word n-grams make documents that
+	// share words but not phrases less alike, character n-grams bring "tokenization" and "tokenizing" together.
+	if !ngrams.Unigrams() {
+		wordVocab := text.BuildVocab(tokens)
+		wordBows := make([]text.SparseVector, len(tokens))
+		for i, doc := range tokens {
+			wordBows[i] = text.SparseCounts(doc, wordVocab)
+		}
+		fmt.Println("\nSimilarities over single words:")
+		for i, s := range pairwiseSimilarities(wordBows) {
+			fmt.Printf("%d-%d: %.4f (n-grams %.4f)\n", s.a+1, s.b+1, s.similarity, similarities[i].similarity)
+		}
 	}
+}
+
+// maxPrintedTerms is the largest vocabulary whose one-hot vectors are printed.
+const maxPrintedTerms = 50
 
-	return tokens
+type documentSimilarity struct {
+	a, b       int
+	similarity float64
 }
 
-// buildVocab Builds out a map of strings to an int value representing where that word is in the corpus.
-func buildVocab(tokens [][]string) map[string]int {
-	vocab := make(map[string]int)
-	index := 0
-
-	for _, doc := range tokens {
-		for _, word := range doc {
-			if _, exists := vocab[word]; !exists {
-				vocab[word] = index
-				index++
-			}
+// pairwiseSimilarities returns the cosine similarity of every pair of documents, in document order.
+func pairwiseSimilarities(bows []text.SparseVector) []documentSimilarity {
+	var similarities []documentSimilarity
+	for i := range bows {
+		for j := i + 1; j < len(bows); j++ {
+			similarities = append(similarities, documentSimilarity{a: i, b: j, similarity: bows[i].Cosine(bows[j])})
 		}
 	}
 
-	return vocab
+	return similarities
 }
 
 // oneHot returns a vector for a word that maps the word in vector space back to the index in the vocabulary.
@@ -144,35 +194,3 @@ func printVectors(vectors [][]int) {
 		fmt.Println(vector)
 	}
 }
-
-func cosineSimilarity(a, b []float64) float64 {
-	assertVectorsLengthEqual(a, b)
-
-	var dot, normA, normB float64
-	for i := range a {
-		dot += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
-
-	if normA == 0 || normB == 0 {
-		return 0
-	}
-
-	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
-}
-
-func assertVectorsLengthEqual(a, b []float64) {
-	if len(a) != len(b) {
-		panic("vectors must have the same length")
-	}
-}
-
-func sliceIntToFloat(s []int) []float64 {
-	floatSlice := make([]float64, len(s))
-	for i, v := range s {
-		floatSlice[i] = float64(v)
-	}
-
-	return floatSlice
-}
@@ -0,0 +1,60 @@
+// Package hashing embeds text without a model, for simple-embedding's local backend and the embedding server.
+package hashing
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embed is a deterministic, offline embedding using the hashing trick over character n-grams.
+// Every lower-cased word is padded with boundary markers ("<sleep>") and split into n-grams of length minN..maxN,
+// and the word itself is a feature too so exact matches count for more than shared fragments.
+// Each feature is hashed into one of dim buckets with a hashed sign so collisions tend to cancel out, and the
+// vector is L2 normalized so cosine similarity reduces to a dot product. Text without words gives all zeros.
+// It knows nothing about meaning, but shared spelling ("sleep" and "sleepe") lands close together.
+func Embed(s string, dim, minN, maxN int) []float32 {
+	vec := make([]float64, dim)
+
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+	for _, word := range words {
+		addFeature(vec, word)
+		runes := []rune("<" + word + ">")
+		for n := minN; n <= maxN; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				addFeature(vec, string(runes[i:i+n]))
+			}
+		}
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	result := make([]float32, dim)
+	if norm == 0 {
+		return result
+	}
+	for i, v := range vec {
+		result[i] = float32(v / norm)
+	}
+
+	return result
+}
+
+func addFeature(vec []float64, feature string) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	sign := 1.0
+	if sum>>63 == 1 {
+		sign = -1.0
+	}
+	vec[sum%uint64(len(vec))] += sign
+}
@@ -0,0 +1,36 @@
+package hashing
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+
+	return sum
+}
+
+func TestEmbed(t *testing.T) {
+	sleep := Embed("To sleep, perchance to dream", 256, 3, 5)
+	if !slices.Equal(sleep, Embed("to SLEEP perchance to dream!", 256, 3, 5)) {
+		t.Error("case and punctuation changed the vector")
+	}
+	if norm := math.Sqrt(dot(sleep, sleep)); math.Abs(norm-1) > 1e-6 {
+		t.Errorf("norm = %v, want 1", norm)
+	}
+
+	sleepe := Embed("To sleepe, perchance to dreame", 256, 3, 5)
+	knife := Embed("Is this a dagger which I see before me", 256, 3, 5)
+	if dot(sleep, sleepe) <= dot(sleep, knife) {
+		t.Errorf("old spelling scored %v, an unrelated line %v", dot(sleep, sleepe), dot(sleep, knife))
+	}
+
+	if empty := Embed(" -- ", 8, 3, 5); !slices.Equal(empty, make([]float32, 8)) {
+		t.Errorf("text without words = %v, want zeros", empty)
+	}
+}
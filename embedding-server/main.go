@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/quinn-collins/embedding-server/server"
+)
+
+// A stand-in for the OpenAI embeddings API so the real langchaingo client can run without network or an API key.
+// The handler lives in package server so tests can run it in an httptest.Server.
+//
+// Point a client at it with a base URL of http://localhost:8089/v1 and any non-empty OPENAI_API_KEY:
+//	go run . -addr :8089 -dim 256
+//	OPENAI_API_KEY=test go run ../simple-embedding -base-url http://localhost:8089/v1
+//
+// Faults can be injected to exercise client error handling, either with flags at startup
+// or at runtime by POSTing the same fields as JSON to /admin/faults:
+//	curl -X POST localhost:8089/admin/faults -d '{"latency":"250ms","throttle_every":3,"fail_rate":0.1}'
+
+func main() {
+	addr := flag.String("addr", ":8089", "address to listen on")
+	dim := flag.Int("dim", 256, "embedding dimension when the request does not ask for one")
+	apiKey := flag.String("api-key", "", "if set, require this bearer token on every request")
+	latency := flag.Duration("latency", 0, "delay added to every embeddings response")
+	throttleEvery := flag.Int("throttle-every", 0, "return 429 for every Nth embeddings request (0 disables)")
+	failRate := flag.Float64("fail-rate", 0, "fraction of embeddings requests that return 500")
+	seed := flag.Int64("seed", 1, "seed for the fault injection random source")
+	flag.Parse()
+
+	if *dim <= 0 {
+		log.Fatalf("dimension must be positive, got %d", *dim)
+	}
+
+	s := server.New(*dim, *apiKey, *seed)
+	err := s.SetFaults(server.Faults{
+		Latency:       server.Duration(*latency),
+		ThrottleEvery: *throttleEvery,
+		FailRate:      *failRate,
+	})
+	if err != nil {
+		log.Fatalf("invalid faults: %v", err)
+	}
+
+	log.Printf("embedding server listening on %s (dim=%d)", *addr, *dim)
+	log.Fatal(http.ListenAndServe(*addr, s.Handler()))
+}
@@ -0,0 +1,306 @@
+// Package server is a stand-in for the OpenAI embeddings API so the real langchaingo client can run without
+// network or an API key, from the embedding-server command or from an httptest.Server in a test.
+// It implements POST /v1/embeddings and returns deterministic vectors derived from the input text,
+// so the same text always embeds to the same vector and texts sharing words land close together.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quinn-collins/embedding-server/hashing"
+)
+
+const (
+	EmbeddingsPath = "/v1/embeddings"
+	FaultsPath     = "/admin/faults"
+
+	// hashMinN and hashMaxN are the character n-gram lengths of the vectors, the ones of the local backend.
+	hashMinN = 3
+	hashMaxN = 5
+)
+
+// Faults make the server misbehave to exercise client error handling. They are set with SetFaults or by POSTing
+// the same fields as JSON to FaultsPath.
+type Faults struct {
+	// Latency is added before every embeddings response.
+	Latency Duration `json:"latency"`
+	// ThrottleEvery returns a 429 for every Nth embeddings request, 0 disables throttling.
+	ThrottleEvery int `json:"throttle_every"`
+	// FailRate is the fraction of embeddings requests that return a 500.
+	FailRate float64 `json:"fail_rate"`
+}
+
+// Server answers embeddings requests.
+type Server struct {
+	dim    int
+	apiKey string
+
+	mu       sync.Mutex
+	faults   Faults
+	requests int
+	rng      *rand.Rand
+}
+
+type embeddingRequest struct {
+	Model      string          `json:"model"`
+	Input      json.RawMessage `json:"input"`
+	Dimensions int             `json:"dimensions,omitempty"`
+}
+
+type embeddingData struct {
+	Object    string    `json:"object"`
+	Embedding []float32 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type embeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type embeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []embeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  embeddingUsage  `json:"usage"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// New returns a server whose vectors have dim entries unless a request asks for other dimensions. With an
+// apiKey every request needs it as its bearer token, and seed drives the random failures of Faults.FailRate.
+func New(dim int, apiKey string, seed int64) *Server {
+	return &Server{
+		dim:    dim,
+		apiKey: apiKey,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Handler serves the embeddings API and the fault settings.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+EmbeddingsPath, s.handleEmbeddings)
+	mux.HandleFunc("GET "+FaultsPath, s.handleGetFaults)
+	mux.HandleFunc("POST "+FaultsPath, s.handleSetFaults)
+
+	return mux
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if s.apiKey != "" && r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+		writeError(w, http.StatusUnauthorized, "invalid_request_error", "incorrect API key provided")
+		return
+	}
+
+	status, latency := s.nextFault()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	switch status {
+	case http.StatusTooManyRequests:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, status, "rate_limit_exceeded", "rate limit reached, please retry")
+		return
+	case http.StatusInternalServerError:
+		writeError(w, status, "server_error", "injected failure")
+		return
+	}
+
+	var req embeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+
+	inputs, err := parseInput(req.Input)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	dim := s.dim
+	if req.Dimensions > 0 {
+		dim = req.Dimensions
+	}
+
+	resp := embeddingResponse{
+		Object: "list",
+		Data:   make([]embeddingData, 0, len(inputs)),
+		Model:  req.Model,
+	}
+	for i, input := range inputs {
+		resp.Data = append(resp.Data, embeddingData{
+			Object:    "embedding",
+			Embedding: embed(input, dim),
+			Index:     i,
+		})
+		tokens := len(strings.Fields(input))
+		resp.Usage.PromptTokens += tokens
+		resp.Usage.TotalTokens += tokens
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+func (s *Server) handleGetFaults(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	faults := s.faults
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(faults)
+}
+
+func (s *Server) handleSetFaults(w http.ResponseWriter, r *http.Request) {
+	var faults Faults
+	if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	if err := s.SetFaults(faults); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	log.Printf("faults updated: %+v", faults)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Validate reports settings the server can't follow.
+func (f Faults) Validate() error {
+	if f.FailRate < 0 || f.FailRate > 1 {
+		return fmt.Errorf("fail_rate must be between 0 and 1, got %v", f.FailRate)
+	}
+	if f.ThrottleEvery < 0 {
+		return fmt.Errorf("throttle_every must not be negative, got %d", f.ThrottleEvery)
+	}
+	if f.Latency < 0 {
+		return fmt.Errorf("latency must not be negative, got %v", time.Duration(f.Latency))
+	}
+
+	return nil
+}
+
+// SetFaults replaces the fault settings and restarts the request count ThrottleEvery goes by.
+func (s *Server) SetFaults(faults Faults) error {
+	if err := faults.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.faults = faults
+	s.requests = 0
+	s.mu.Unlock()
+
+	return nil
+}
+
+// nextFault decides how the current request should misbehave, if at all.
+func (s *Server) nextFault() (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	latency := time.Duration(s.faults.Latency)
+
+	if s.faults.ThrottleEvery > 0 && s.requests%s.faults.ThrottleEvery == 0 {
+		return http.StatusTooManyRequests, latency
+	}
+	if s.faults.FailRate > 0 && s.rng.Float64() < s.faults.FailRate {
+		return http.StatusInternalServerError, latency
+	}
+
+	return http.StatusOK, latency
+}
+
+// parseInput accepts either a single string or a list of strings, like the OpenAI API does.
+func parseInput(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("input is required")
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	if len(many) == 0 {
+		return nil, fmt.Errorf("input must not be empty")
+	}
+
+	return many, nil
+}
+
+// embed returns the vectors simple-embedding's local backend computes, see hashing.Embed.
+// Nothing here is semantic, but it is deterministic and texts that share words score higher than ones that don't.
+func embed(input string, dim int) []float32 {
+	vec := hashing.Embed(input, dim, hashMinN, hashMaxN)
+	if slices.ContainsFunc(vec, func(v float32) bool { return v != 0 }) {
+		return vec
+	}
+
+	// An all-zero vector breaks cosine similarity downstream, so empty text gets a fixed unit vector.
+	vec[0] = 1
+	return vec
+}
+
+func writeError(w http.ResponseWriter, status int, errType, message string) {
+	var resp errorResponse
+	resp.Error.Message = message
+	resp.Error.Type = errType
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Duration lets faults be written as "250ms" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"250ms\": %w", err)
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+
+	return nil
+}
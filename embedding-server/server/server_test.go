@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, url, body string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func TestEmbeddings(t *testing.T) {
+	ts := httptest.NewServer(New(16, "", 1).Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantDims   []int
+	}{
+		{`{"model":"m","input":"to sleep"}`, http.StatusOK, []int{16}},
+		{`{"model":"m","input":["to sleep","perchance"],"dimensions":8}`, http.StatusOK, []int{8, 8}},
+		{`{"model":"m","input":[]}`, http.StatusBadRequest, nil},
+		{`{"model":"m","input":3}`, http.StatusBadRequest, nil},
+		{`{"model":"m"}`, http.StatusBadRequest, nil},
+		{`not json`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		resp := post(t, ts.URL+EmbeddingsPath, tt.body, nil)
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.body, resp.StatusCode, tt.wantStatus)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		var got embeddingResponse
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		var dims []int
+		for i, d := range got.Data {
+			if d.Index != i {
+				t.Errorf("%s: entry %d has index %d", tt.body, i, d.Index)
+			}
+			dims = append(dims, len(d.Embedding))
+		}
+		if !slices.Equal(dims, tt.wantDims) {
+			t.Errorf("%s: dimensions %v, want %v", tt.body, dims, tt.wantDims)
+		}
+	}
+}
+
+func TestEmbedEmptyText(t *testing.T) {
+	if vec := embed("", 4); vec[0] != 1 || vec[1] != 0 {
+		t.Errorf("embed of empty text = %v, want a unit vector", vec)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	ts := httptest.NewServer(New(4, "secret", 1).Handler())
+	defer ts.Close()
+
+	body := `{"model":"m","input":"x"}`
+	if resp := post(t, ts.URL+EmbeddingsPath, body, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without a key: status %d, want 401", resp.StatusCode)
+	}
+	header := http.Header{"Authorization": {"Bearer secret"}}
+	if resp := post(t, ts.URL+EmbeddingsPath, body, header); resp.StatusCode != http.StatusOK {
+		t.Errorf("with the key: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	s := New(4, "", 1)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	for _, body := range []string{`{"fail_rate":1.5}`, `{"fail_rate":-0.1}`, `{"throttle_every":-1}`, `{"latency":"-1s"}`, `{"latency":5}`} {
+		if resp := post(t, ts.URL+FaultsPath, body, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, resp.StatusCode)
+		}
+	}
+	if err := s.SetFaults(Faults{FailRate: 2}); err == nil {
+		t.Error("SetFaults accepted a fail rate of 2")
+	}
+
+	if resp := post(t, ts.URL+FaultsPath, `{"throttle_every":2,"latency":"1ms"}`, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("setting faults: status %d", resp.StatusCode)
+	}
+	resp, err := http.Get(ts.URL + FaultsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got Faults
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Faults{ThrottleEvery: 2, Latency: Duration(time.Millisecond)}); got != want {
+		t.Errorf("faults = %+v, want %+v", got, want)
+	}
+
+	var statuses []int
+	for range 4 {
+		statuses = append(statuses, post(t, ts.URL+EmbeddingsPath, `{"model":"m","input":"x"}`, nil).StatusCode)
+	}
+	if want := []int{200, 429, 200, 429}; !slices.Equal(statuses, want) {
+		t.Errorf("statuses %v, want %v", statuses, want)
+	}
+
+	if err := s.SetFaults(Faults{FailRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := post(t, ts.URL+EmbeddingsPath, `{"model":"m","input":"x"}`, nil); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("fail rate 1: status %d, want 500", resp.StatusCode)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type BatchOptions struct {
+	// MaxBatchTokens caps the estimated tokens sent in a single request.
+	MaxBatchTokens int
+	// MaxBatchSize caps the number of texts sent in a single request.
+	MaxBatchSize int
+	// Workers is how many requests may be in flight at once.
+	Workers int
+	// MaxRetries is how many times a batch is retried after a retryable error.
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the exponential backoff between retries.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RequestsPerSecond limits how often requests start, 0 means unlimited.
+	RequestsPerSecond float64
+	// Progress, if set, is called after every finished batch with the number of texts processed so far, failed or not.
+	Progress func(done, total int)
+}
+
+func defaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		MaxBatchTokens: 8000,
+		MaxBatchSize:   256,
+		Workers:        4,
+		MaxRetries:     5,
+		BaseDelay:      500 * time.Millisecond,
+		MaxDelay:       30 * time.Second,
+	}
+}
+
+// BatchFailure is a range of texts, [Start, End), that could not be embedded.
+type BatchFailure struct {
+	Start int
+	End   int
+	Err   error
+}
+
+// BatchError is returned when some batches failed after all retries.
+// The vectors for every other text are still returned, the failed ones are nil.
+type BatchError struct {
+	Failures []BatchFailure
+	Total    int
+}
+
+func (e *BatchError) Error() string {
+	failed := 0
+	for _, f := range e.Failures {
+		failed += f.End - f.Start
+	}
+
+	return fmt.Sprintf("failed to embed %d of %d texts in %d batches, first error: %v", failed, e.Total, len(e.Failures), e.Failures[0].Err)
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		errs = append(errs, f.Err)
+	}
+
+	return errs
+}
+
+// batchingEmbedder splits large EmbedDocuments calls into token-budgeted batches and sends them
+// from a bounded pool of workers, retrying rate limits (429) and server errors (5xx) with
+// exponential backoff and full jitter. Queries are small so they are only retried, never batched.
+type batchingEmbedder struct {
+	inner Embedder
+	opts  BatchOptions
+
+	limiter <-chan time.Time
+}
+
+func newBatchingEmbedder(inner Embedder, opts BatchOptions) *batchingEmbedder {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.MaxBatchSize < 1 {
+		opts.MaxBatchSize = 1
+	}
+
+	b := &batchingEmbedder{
+		inner: inner,
+		opts:  opts,
+	}
+	if opts.RequestsPerSecond > 0 {
+		b.limiter = time.Tick(time.Duration(float64(time.Second) / opts.RequestsPerSecond))
+	}
+
+	return b
+}
+
+type batch struct {
+	start int
+	end   int
+}
+
+func (b *batchingEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+	batches := b.split(texts)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan batch)
+	var mu sync.Mutex
+	var failures []BatchFailure
+	done := 0
+
+	var wg sync.WaitGroup
+	for range min(b.opts.Workers, len(batches)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				vectors, err := b.embedWithRetry(ctx, texts[job.start:job.end])
+
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, BatchFailure{Start: job.start, End: job.end, Err: err})
+				} else {
+					copy(result[job.start:job.end], vectors)
+				}
+				done += job.end - job.start
+				if b.opts.Progress != nil {
+					b.opts.Progress(done, len(texts))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range batches {
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// A cancelled caller wants nothing back, not a partial result.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(failures) > 0 {
+		return result, &BatchError{Failures: failures, Total: len(texts)}
+	}
+
+	return result, nil
+}
+
+func (b *batchingEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := b.retry(ctx, func() ([][]float32, error) {
+		vec, err := b.inner.EmbedQuery(ctx, text)
+		return [][]float32{vec}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vectors[0], nil
+}
+
+// split groups consecutive texts so that no batch goes over the token budget or the batch size.
+// A single text over the budget still gets a batch of its own and the API decides whether it fits.
+func (b *batchingEmbedder) split(texts []string) []batch {
+	var batches []batch
+	start, tokens := 0, 0
+	for i, text := range texts {
+		t := estimateTokens(text)
+		full := i-start >= b.opts.MaxBatchSize || (b.opts.MaxBatchTokens > 0 && tokens+t > b.opts.MaxBatchTokens)
+		if i > start && full {
+			batches = append(batches, batch{start: start, end: i})
+			start, tokens = i, 0
+		}
+		tokens += t
+	}
+	if start < len(texts) {
+		batches = append(batches, batch{start: start, end: len(texts)})
+	}
+
+	return batches
+}
+
+func (b *batchingEmbedder) embedWithRetry(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := b.retry(ctx, func() ([][]float32, error) {
+		return b.inner.EmbedDocuments(ctx, texts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+
+	return vectors, nil
+}
+
+func (b *batchingEmbedder) retry(ctx context.Context, call func() ([][]float32, error)) ([][]float32, error) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		// Batches still queued when the caller gives up are dropped rather than sent.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if b.limiter != nil {
+			select {
+			case <-b.limiter:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var vectors [][]float32
+		vectors, err = call()
+		if err == nil {
+			return vectors, nil
+		}
+		if attempt >= b.opts.MaxRetries || !isRetryable(err) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(b.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// backoff picks a random delay between zero and the exponential cap for this attempt ("full jitter").
+func (b *batchingEmbedder) backoff(attempt int) time.Duration {
+	ceiling := b.opts.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > b.opts.MaxDelay {
+		ceiling = b.opts.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// isRetryable reports whether err looks transient: rate limits, server errors, network timeouts and dropped
+// connections.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// estimateTokens uses the rule of thumb of roughly four characters per token for English text.
+func estimateTokens(text string) int {
+	return len(text)/4 + 1
+}
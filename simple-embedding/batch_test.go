@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyEmbedder returns a vector holding the length of every text and fails the calls fail picks. call counts
+// from 1 across all workers.
+type flakyEmbedder struct {
+	fail func(call int, texts []string) error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (e *flakyEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.calls++
+	call := e.calls
+	e.mu.Unlock()
+
+	if e.fail != nil {
+		if err := e.fail(call, texts); err != nil {
+			return nil, err
+		}
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text))}
+	}
+
+	return vectors, nil
+}
+
+func (e *flakyEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := e.EmbedDocuments(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+
+	return vectors[0], nil
+}
+
+func fastBatchOptions() BatchOptions {
+	opts := defaultBatchOptions()
+	opts.BaseDelay, opts.MaxDelay = time.Millisecond, 2*time.Millisecond
+	return opts
+}
+
+func TestSplit(t *testing.T) {
+	// estimateTokens counts a 12 byte text as 4 tokens and a 40 byte one as 11.
+	short, long := strings.Repeat("x", 12), strings.Repeat("x", 40)
+	tests := []struct {
+		name           string
+		texts          []string
+		tokens, size   int
+		wantBatchSizes []int
+	}{
+		{"token budget", []string{short, short, short, short, short}, 8, 10, []int{2, 2, 1}},
+		{"batch size", []string{short, short, short, short, short}, 100, 2, []int{2, 2, 1}},
+		{"text over the budget", []string{short, long, short}, 8, 10, []int{1, 1, 1}},
+		{"no budget", []string{long, long, long}, 0, 10, []int{3}},
+		{"nothing", nil, 8, 10, nil},
+	}
+	for _, tt := range tests {
+		opts := defaultBatchOptions()
+		opts.MaxBatchTokens, opts.MaxBatchSize = tt.tokens, tt.size
+		var sizes []int
+		next := 0
+		for _, b := range newBatchingEmbedder(nil, opts).split(tt.texts) {
+			if b.start != next {
+				t.Errorf("%s: batch starts at %d, want %d", tt.name, b.start, next)
+			}
+			sizes = append(sizes, b.end-b.start)
+			next = b.end
+		}
+		if !slices.Equal(sizes, tt.wantBatchSizes) {
+			t.Errorf("%s: batch sizes %v, want %v", tt.name, sizes, tt.wantBatchSizes)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      func(call int, texts []string) error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"rate limited twice", func(call int, _ []string) error {
+			if call <= 2 {
+				return &StatusError{Code: http.StatusTooManyRequests, Err: errors.New("slow down")}
+			}
+			return nil
+		}, 3, false},
+		{"server error", func(call int, _ []string) error {
+			if call == 1 {
+				return fmt.Errorf("embed: %w", &StatusError{Code: http.StatusBadGateway, Err: errors.New("bad gateway")})
+			}
+			return nil
+		}, 2, false},
+		{"bad request", func(int, []string) error {
+			return &StatusError{Code: http.StatusBadRequest, Err: errors.New("too long")}
+		}, 1, true},
+		{"retries used up", func(int, []string) error {
+			return &StatusError{Code: http.StatusServiceUnavailable, Err: errors.New("down")}
+		}, 4, true},
+	}
+	for _, tt := range tests {
+		inner := &flakyEmbedder{fail: tt.fail}
+		opts := fastBatchOptions()
+		opts.MaxRetries = 3
+		_, err := newBatchingEmbedder(inner, opts).EmbedQuery(context.Background(), "to be")
+		if (err != nil) != tt.wantErr || inner.calls != tt.wantCalls {
+			t.Errorf("%s: %d calls and error %v, want %d calls and an error %t", tt.name, inner.calls, err, tt.wantCalls, tt.wantErr)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&StatusError{Code: 429, Err: errors.New("x")}, true},
+		{&StatusError{Code: 500, Err: errors.New("x")}, true},
+		{fmt.Errorf("wrapped: %w", &StatusError{Code: 503, Err: errors.New("x")}), true},
+		{&StatusError{Code: 400, Err: errors.New("x")}, false},
+		{&StatusError{Code: 401, Err: errors.New("x")}, false},
+		{errors.New("API returned unexpected status code: 429"), false},
+		{context.Canceled, false},
+		{fmt.Errorf("request: %w", context.DeadlineExceeded), false},
+		{&net.DNSError{IsTimeout: true}, true},
+		{&net.DNSError{IsNotFound: true}, false},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("isRetryable(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	opts := defaultBatchOptions()
+	opts.BaseDelay, opts.MaxDelay = 10*time.Millisecond, 100*time.Millisecond
+	b := newBatchingEmbedder(nil, opts)
+
+	for attempt, ceiling := range []time.Duration{10, 20, 40, 80, 100, 100, 100} {
+		ceiling *= time.Millisecond
+		seen := make(map[time.Duration]bool)
+		for range 50 {
+			d := b.backoff(attempt)
+			if d < 0 || d >= ceiling {
+				t.Fatalf("attempt %d: backoff %v, want below %v", attempt, d, ceiling)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("attempt %d: the backoff is not jittered", attempt)
+		}
+	}
+	if d := b.backoff(70); d < 0 || d >= opts.MaxDelay {
+		t.Errorf("an overflowing shift gave a backoff of %v", d)
+	}
+}
+
+// TestPartialFailure fails every attempt at the batch holding "bad" and keeps the vectors of the others.
+func TestPartialFailure(t *testing.T) {
+	down := &StatusError{Code: http.StatusInternalServerError, Err: errors.New("down")}
+	inner := &flakyEmbedder{fail: func(_ int, texts []string) error {
+		if slices.Contains(texts, "bad") {
+			return down
+		}
+		return nil
+	}}
+	opts := fastBatchOptions()
+	opts.MaxBatchSize, opts.MaxRetries, opts.Workers = 2, 1, 3
+
+	var mu sync.Mutex
+	var progress []int
+	opts.Progress = func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if total != 6 {
+			t.Errorf("Progress total %d, want 6", total)
+		}
+		progress = append(progress, done)
+	}
+
+	texts := []string{"a", "bb", "bad", "dddd", "eeeee", "f"}
+	vectors, err := newBatchingEmbedder(inner, opts).EmbedDocuments(context.Background(), texts)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("got %v, want a BatchError", err)
+	}
+	if len(batchErr.Failures) != 1 || batchErr.Failures[0].Start != 2 || batchErr.Failures[0].End != 4 || batchErr.Total != 6 {
+		t.Errorf("failures %+v of %d, want texts 2 to 4 of 6", batchErr.Failures, batchErr.Total)
+	}
+	if !errors.Is(err, down) {
+		t.Errorf("%v does not wrap the failure of the batch", err)
+	}
+	for i, vec := range vectors {
+		if failed := i == 2 || i == 3; failed != (vec == nil) || (vec != nil && vec[0] != float32(len(texts[i]))) {
+			t.Errorf("vector %d is %v", i, vec)
+		}
+	}
+	if inner.calls != 4 {
+		t.Errorf("%d calls, want one per batch and a retry of the failing one", inner.calls)
+	}
+	slices.Sort(progress)
+	if !slices.Equal(progress, []int{2, 4, 6}) {
+		t.Errorf("progress %v, want 2, 4 and 6", progress)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inner := &flakyEmbedder{}
+	opts := fastBatchOptions()
+	opts.MaxBatchSize, opts.Workers = 1, 1
+	// Cancelling after the first batch leaves the other nine unsent.
+	opts.Progress = func(done, total int) { cancel() }
+
+	vectors, err := newBatchingEmbedder(inner, opts).EmbedDocuments(ctx, make([]string, 10))
+	if !errors.Is(err, context.Canceled) || vectors != nil {
+		t.Errorf("got %d vectors and %v, want nothing and %v", len(vectors), err, context.Canceled)
+	}
+	if inner.calls != 1 {
+		t.Errorf("%d batches sent, want only the one before cancelling", inner.calls)
+	}
+
+	// A cancelled wait between retries returns at once.
+	ctx, cancel = context.WithCancel(context.Background())
+	inner = &flakyEmbedder{fail: func(int, []string) error {
+		cancel()
+		return &StatusError{Code: http.StatusTooManyRequests, Err: errors.New("slow down")}
+	}}
+	opts = defaultBatchOptions()
+	opts.BaseDelay = time.Hour
+	if _, err := newBatchingEmbedder(inner, opts).EmbedQuery(ctx, "to be"); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	inner := &flakyEmbedder{}
+	opts := fastBatchOptions()
+	opts.MaxBatchSize, opts.Workers, opts.RequestsPerSecond = 1, 5, 100
+
+	start := time.Now()
+	if _, err := newBatchingEmbedder(inner, opts).EmbedDocuments(context.Background(), make([]string, 5)); err != nil {
+		t.Fatal(err)
+	}
+	// Five requests at 100 a second take at least 50ms, however many workers send them.
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("5 requests took %v, want the limiter to space them 10ms apart", elapsed)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// The benchmarks search all three plays embedded with the offline local embedder, so they need no API key:
+//
+//	go test -run '^$' -bench .
+//
+// Queries are the embeddings of randomly picked passages, the same ones for every search method.
+
+const (
+	benchK       = 5
+	benchQueries = 200
+)
+
+var (
+	benchOnce     sync.Once
+	benchSearcher *Searcher
+	benchErr      error
+)
+
+// loadBenchSearcher embeds the corpus once for every test and benchmark in the package.
+func loadBenchSearcher(tb testing.TB) *Searcher {
+	tb.Helper()
+
+	benchOnce.Do(func() {
+		docs, err := loadCorpus("./corpora")
+		if err != nil {
+			benchErr = err
+			return
+		}
+		chunker, err := newChunker(ChunkLine, 64, 16)
+		if err != nil {
+			benchErr = err
+			return
+		}
+		benchSearcher = newSearcher(context.Background(), newLocalEmbedder(512), chunkCorpus(docs, chunker), false)
+	})
+	if benchErr != nil {
+		tb.Fatal(benchErr)
+	}
+
+	return benchSearcher
+}
+
+// pickQueries returns n embeddings picked at random, skipping passages that were never embedded.
+// With nothing embedded there is nothing to query with and the caller is skipped.
+func pickQueries(tb testing.TB, embeddings [][]float32, n int, seed int64) []int {
+	tb.Helper()
+
+	var embedded []int
+	for i, v := range embeddings {
+		if v != nil {
+			embedded = append(embedded, i)
+		}
+	}
+	if len(embedded) == 0 {
+		tb.Skip("no embedded passages to query with")
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	picked := make([]int, n)
+	for i := range picked {
+		picked[i] = embedded[rng.Intn(len(embedded))]
+	}
+
+	return picked
+}
+
+// BenchmarkSearch compares the original linear scan that widens every vector to float64 and sorts every score
+// with the float32 heap scan on one core and across every core.
+func BenchmarkSearch(b *testing.B) {
+	s := loadBenchSearcher(b)
+	queries := pickQueries(b, s.embeddings, benchQueries, 1)
+
+	searches := []struct {
+		name   string
+		search func(q []float32) []Match
+	}{
+		{"linear-float64", func(q []float32) []Match { return topMatches(querySimilarities(q, s.embeddings), benchK) }},
+		{"heap-1-core", func(q []float32) []Match { return topKCosine(q, s.embeddings, s.norms, benchK, 1) }},
+		{"heap-all-cores", func(q []float32) []Match { return topKCosine(q, s.embeddings, s.norms, benchK, 0) }},
+	}
+	for _, bs := range searches {
+		b.Run(bs.name, func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				bs.search(s.embeddings[queries[i%len(queries)]])
+			}
+		})
+	}
+}
+
+// TestHeapSearchMatchesLinearScan checks that the fast paths agree with the original on what ranks where.
+func TestHeapSearchMatchesLinearScan(t *testing.T) {
+	s := loadBenchSearcher(t)
+	for _, i := range pickQueries(t, s.embeddings, 20, 1) {
+		q := s.embeddings[i]
+		want := topMatches(querySimilarities(q, s.embeddings), benchK)
+		for _, workers := range []int{1, 0} {
+			if got := topKCosine(q, s.embeddings, s.norms, benchK, workers); !sameRanking(want, got) {
+				t.Errorf("query %d with %d workers: got %v, want %v", i, workers, got, want)
+			}
+		}
+	}
+}
+
+// BenchmarkHNSW holds random passages out of an index built from the rest and uses them as queries, so no query
+// finds itself. Every efSearch value reports its recall@k, the share of the exact top k under the index's metric
+// that the index also returned. The last run deletes a tenth of the index first to show that tombstoned nodes
+// still route searches.
+func BenchmarkHNSW(b *testing.B) {
+	s := loadBenchSearcher(b)
+	opts := defaultHNSWOptions()
+
+	held := make(map[int]bool)
+	var queries [][]float32
+	for _, i := range pickQueries(b, s.embeddings, benchQueries, 2) {
+		if !held[i] {
+			held[i] = true
+			queries = append(queries, s.embeddings[i])
+		}
+	}
+	rest := make([][]float32, len(s.embeddings))
+	for i, v := range s.embeddings {
+		if !held[i] {
+			rest[i] = v
+		}
+	}
+
+	index, err := buildHNSW(rest, opts)
+	if err != nil {
+		b.Fatalf("failed to build HNSW index: %v", err)
+	}
+
+	exactResults := func() [][]Match {
+		results := make([][]Match, len(queries))
+		for i, q := range queries {
+			results[i], _ = index.exactSearch(q, benchK)
+		}
+		return results
+	}
+	run := func(name string, ef int, exact [][]Match) {
+		index.SetEfSearch(ef)
+		approx := make([][]Match, len(queries))
+		for i, q := range queries {
+			approx[i], _ = index.Search(q, benchK)
+		}
+		recall := recallAtK(exact, approx)
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				index.Search(queries[i%len(queries)], benchK)
+			}
+			b.ReportMetric(recall, fmt.Sprintf("recall@%d", benchK))
+		})
+	}
+
+	b.Run("exact", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			index.exactSearch(queries[i%len(queries)], benchK)
+		}
+	})
+	exact := exactResults()
+	for _, ef := range []int{benchK, 16, 32, 64, 128, 256} {
+		run(fmt.Sprintf("ef=%d", ef), ef, exact)
+	}
+
+	rng := rand.New(rand.NewSource(3))
+	for i := range rest {
+		if rest[i] != nil && rng.Intn(10) == 0 {
+			index.Delete(i)
+		}
+	}
+	run(fmt.Sprintf("ef=%d-10%%-deleted", opts.EfSearch), opts.EfSearch, exactResults())
+}
+
+// recallAtK averages, over every query, the fraction of the exact results that the approximate search found.
+func recallAtK(exact, approx [][]Match) float64 {
+	var total float64
+	for i := range exact {
+		if len(exact[i]) == 0 {
+			total++
+			continue
+		}
+		found := make(map[int]bool, len(approx[i]))
+		for _, m := range approx[i] {
+			found[m.Index] = true
+		}
+		hits := 0
+		for _, m := range exact[i] {
+			if found[m.Index] {
+				hits++
+			}
+		}
+		total += float64(hits) / float64(len(exact[i]))
+	}
+
+	return total / float64(max(1, len(exact)))
+}
+
+// sameRanking compares indexes, allowing float32 rounding to swap neighbours that score within a hair of each other.
+func sameRanking(a, b []Match) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Index != b[i].Index && math.Abs(a[i].Score-b[i].Score) > 1e-5 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// The original search: widen every vector to float64, score every document and sort them all.
+// topMatches returns the k highest scoring matches, best first, skipping documents that were never embedded.
+func topMatches(similarities []float64, k int) []Match {
+	matches := make([]Match, 0, len(similarities))
+	for i, score := range similarities {
+		if math.IsInf(score, -1) {
+			continue
+		}
+		matches = append(matches, Match{
+			Index: i,
+			Score: score,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+
+	return matches
+}
+
+// querySimilarities scores every document against the query, documents that failed to embed score -Inf.
+func querySimilarities(query []float32, documentEmbeddings [][]float32) []float64 {
+	var results []float64
+	for _, doc := range documentEmbeddings {
+		if doc == nil {
+			results = append(results, math.Inf(-1))
+			continue
+		}
+		results = append(results, cosineSimilarity(widenFloats(doc), widenFloats(query)))
+	}
+
+	return results
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return math.Inf(-1)
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func widenFloats(fs []float32) []float64 {
+	result := make([]float64, 0, len(fs))
+	for _, f := range fs {
+		result = append(result, float64(f))
+	}
+
+	return result
+}
@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheFileName holds every cached vector in the cache directory as a header followed by an append-only list of records.
+// The header is cacheMagic and the record version (uint16).
+// Each record is: model length (uint16), model, dimension (uint32), SHA-256 of the text, vector length (uint32), float32s.
+// All integers and floats are little endian. A torn record at the end of the file (e.g. from a crash) is dropped on load.
+const cacheFileName = "embeddings.cache"
+
+// cacheVersion 2 started naming the base URL of compatible servers in the model and recording the dimension of
+// OpenAI models, version 1 files (which had no header) can hold another server's vectors under an OpenAI name.
+const (
+	cacheMagic   = "EMBC"
+	cacheVersion = 2
+
+	cacheHeaderLen = len(cacheMagic) + 2
+)
+
+// maxCachedVectorLen bounds the vector length read from a record, a corrupted length would otherwise allocate
+// gigabytes before the read fails.
+const maxCachedVectorLen = 1 << 16
+
+type cacheKey struct {
+	model string
+	dim   int
+	hash  [sha256.Size]byte
+}
+
+type CacheStats struct {
+	Hits    int
+	Misses  int
+	Entries int
+}
+
+// cachedEmbedder wraps another Embedder and keeps every vector it returns on disk.
+// Entries are keyed by the model name, the configured dimension and the SHA-256 of the text,
+// so switching models or dimensions never serves a stale vector. Only misses are sent upstream.
+type cachedEmbedder struct {
+	inner Embedder
+	model string
+	dim   int
+	path  string
+
+	mu      sync.Mutex
+	entries map[cacheKey][]float32
+	hits    int
+	misses  int
+}
+
+// newCachedEmbedder loads the cache from dir, creating it if needed.
+// dim is the dimension the embedder was configured with, 0 meaning the model default.
+func newCachedEmbedder(inner Embedder, dir, model string, dim int) (*cachedEmbedder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+
+	c := &cachedEmbedder{
+		inner:   inner,
+		model:   model,
+		dim:     dim,
+		path:    filepath.Join(dir, cacheFileName),
+		entries: make(map[cacheKey][]float32),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *cachedEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, len(texts))
+
+	c.mu.Lock()
+	var missTexts []string
+	var missIndexes []int
+	for i, text := range texts {
+		if vec, ok := c.entries[c.key(text)]; ok {
+			result[i] = vec
+			c.hits++
+			continue
+		}
+		missTexts = append(missTexts, text)
+		missIndexes = append(missIndexes, i)
+	}
+	c.misses += len(missTexts)
+	c.mu.Unlock()
+
+	if len(missTexts) == 0 {
+		return result, nil
+	}
+
+	vectors, err := c.inner.EmbedDocuments(ctx, missTexts)
+	var batchErr *BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		return nil, err
+	}
+	if len(vectors) != len(missTexts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(missTexts))
+	}
+
+	// Only cache what was embedded, a partial failure leaves nil vectors behind.
+	var storeTexts []string
+	var storeVectors [][]float32
+	for i, vec := range vectors {
+		result[missIndexes[i]] = vec
+		if vec != nil {
+			storeTexts = append(storeTexts, missTexts[i])
+			storeVectors = append(storeVectors, vec)
+		}
+	}
+	if err := c.store(storeTexts, storeVectors); err != nil {
+		return nil, err
+	}
+
+	if batchErr != nil {
+		return result, remapBatchError(batchErr, missIndexes, len(texts))
+	}
+
+	return result, nil
+}
+
+// remapBatchError translates failures reported against the misses back to positions in the caller's texts.
+func remapBatchError(err *BatchError, indexes []int, total int) *BatchError {
+	remapped := &BatchError{Total: total}
+	for _, f := range err.Failures {
+		for i := f.Start; i < f.End; i++ {
+			n := len(remapped.Failures)
+			if n > 0 && remapped.Failures[n-1].End == indexes[i] && remapped.Failures[n-1].Err == f.Err {
+				remapped.Failures[n-1].End++
+				continue
+			}
+			remapped.Failures = append(remapped.Failures, BatchFailure{Start: indexes[i], End: indexes[i] + 1, Err: f.Err})
+		}
+	}
+
+	return remapped
+}
+
+func (c *cachedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	c.mu.Lock()
+	if vec, ok := c.entries[c.key(text)]; ok {
+		c.hits++
+		c.mu.Unlock()
+		return vec, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	vec, err := c.inner.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store([]string{text}, [][]float32{vec}); err != nil {
+		return nil, err
+	}
+
+	return vec, nil
+}
+
+func (c *cachedEmbedder) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: len(c.entries),
+	}
+}
+
+// Invalidate drops the cached vectors for texts under the current model and dimension.
+func (c *cachedEmbedder) Invalidate(texts []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, text := range texts {
+		delete(c.entries, c.key(text))
+	}
+
+	return c.rewrite()
+}
+
+// InvalidateModel drops every cached vector for the current model and dimension.
+func (c *cachedEmbedder) InvalidateModel() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.model == c.model && key.dim == c.dim {
+			delete(c.entries, key)
+		}
+	}
+
+	return c.rewrite()
+}
+
+// Clear drops every cached vector for every model.
+func (c *cachedEmbedder) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[cacheKey][]float32)
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove cache file: %w", err)
+	}
+
+	return nil
+}
+
+func (c *cachedEmbedder) key(text string) cacheKey {
+	return cacheKey{
+		model: c.model,
+		dim:   c.dim,
+		hash:  sha256.Sum256([]byte(text)),
+	}
+}
+
+// store records new vectors in memory and appends them to the cache file.
+func (c *cachedEmbedder) store(texts []string, vectors [][]float32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open cache file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat cache file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	if info.Size() == 0 {
+		if err := writeCacheHeader(w); err != nil {
+			return fmt.Errorf("write cache header: %w", err)
+		}
+	}
+	for i, text := range texts {
+		key := c.key(text)
+		c.entries[key] = vectors[i]
+		if err := writeCacheRecord(w, key, vectors[i]); err != nil {
+			return fmt.Errorf("write cache record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write cache file: %w", err)
+	}
+
+	return nil
+}
+
+// rewrite replaces the cache file with the current entries. Callers must hold c.mu.
+func (c *cachedEmbedder) rewrite() error {
+	tmp := c.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("create cache file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	if err := writeCacheHeader(w); err != nil {
+		f.Close()
+		return fmt.Errorf("write cache header: %w", err)
+	}
+	for key, vec := range c.entries {
+		if err := writeCacheRecord(w, key, vec); err != nil {
+			f.Close()
+			return fmt.Errorf("write cache record: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close cache file: %w", err)
+	}
+
+	return os.Rename(tmp, c.path)
+}
+
+func (c *cachedEmbedder) load() error {
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open cache file: %w", err)
+	}
+	defer f.Close()
+
+	r := &countingReader{r: bufio.NewReader(f)}
+	version, err := readCacheHeader(r)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil || version != cacheVersion {
+		// Vectors of another version may be keyed differently, start over rather than serve them.
+		fmt.Fprintf(os.Stderr, "embedding cache %s was written by another version, starting a new one\n", c.path)
+		return os.Remove(c.path)
+	}
+
+	good := r.n
+	for {
+		key, vec, err := readCacheRecord(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// Cut the torn record off so later appends start on a record boundary.
+			return os.Truncate(c.path, good)
+		}
+		if err != nil {
+			return fmt.Errorf("read cache file %s: %w", c.path, err)
+		}
+		good = r.n
+		if key.dim != 0 && len(vec) != key.dim {
+			continue
+		}
+		c.entries[key] = vec
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func writeCacheHeader(w io.Writer) error {
+	buf := binary.LittleEndian.AppendUint16([]byte(cacheMagic), cacheVersion)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readCacheHeader returns the record version of the file, or an error if it doesn't start with a header.
+func readCacheHeader(r io.Reader) (int, error) {
+	buf := make([]byte, cacheHeaderLen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	if string(buf[:len(cacheMagic)]) != cacheMagic {
+		return 0, errors.New("not an embedding cache file")
+	}
+
+	return int(binary.LittleEndian.Uint16(buf[len(cacheMagic):])), nil
+}
+
+func writeCacheRecord(w io.Writer, key cacheKey, vec []float32) error {
+	buf := make([]byte, 0, 2+len(key.model)+4+sha256.Size+4+4*len(vec))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(key.model)))
+	buf = append(buf, key.model...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(key.dim))
+	buf = append(buf, key.hash[:]...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(vec)))
+	for _, f := range vec {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(f))
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func readCacheRecord(r io.Reader) (cacheKey, []float32, error) {
+	var key cacheKey
+
+	var modelLen uint16
+	if err := binary.Read(r, binary.LittleEndian, &modelLen); err != nil {
+		return key, nil, err
+	}
+	model := make([]byte, modelLen)
+	if _, err := io.ReadFull(r, model); err != nil {
+		return key, nil, unexpectedEOF(err)
+	}
+	key.model = string(model)
+
+	var dim uint32
+	if err := binary.Read(r, binary.LittleEndian, &dim); err != nil {
+		return key, nil, unexpectedEOF(err)
+	}
+	key.dim = int(dim)
+
+	if _, err := io.ReadFull(r, key.hash[:]); err != nil {
+		return key, nil, unexpectedEOF(err)
+	}
+
+	var vecLen uint32
+	if err := binary.Read(r, binary.LittleEndian, &vecLen); err != nil {
+		return key, nil, unexpectedEOF(err)
+	}
+	if vecLen > maxCachedVectorLen {
+		return key, nil, fmt.Errorf("vector length %d is over the limit of %d", vecLen, maxCachedVectorLen)
+	}
+	raw := make([]byte, 4*int(vecLen))
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return key, nil, unexpectedEOF(err)
+	}
+	vec := make([]float32, vecLen)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+	}
+
+	return key, vec, nil
+}
+
+// unexpectedEOF turns a clean EOF in the middle of a record into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// defaultCacheDir keeps cached vectors out of the repository.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ".embedding-cache"
+	}
+
+	return filepath.Join(dir, "rag-history", "simple-embedding")
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// countingEmbedder counts the texts that get past the cache.
+type countingEmbedder struct {
+	Embedder
+	texts int
+}
+
+func (e *countingEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.Embedder.EmbedDocuments(ctx, texts)
+}
+
+func (e *countingEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	e.texts++
+	return e.Embedder.EmbedQuery(ctx, text)
+}
+
+func openCache(t *testing.T, dir, model string, dim int) (*cachedEmbedder, *countingEmbedder) {
+	t.Helper()
+
+	inner := &countingEmbedder{Embedder: newLocalEmbedder(max(dim, 4))}
+	c, err := newCachedEmbedder(inner, dir, model, dim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, inner
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, inner := openCache(t, dir, "m", 8)
+	first, err := c.EmbedDocuments(ctx, []string{"to be", "or not"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := c.EmbedQuery(ctx, "to be")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.texts != 2 || !slices.Equal(query, first[0]) {
+		t.Errorf("embedded %d texts, want 2 with the query served from the cache", inner.texts)
+	}
+
+	c, inner = openCache(t, dir, "m", 8)
+	again, err := c.EmbedDocuments(ctx, []string{"or not", "to be", "that is"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.texts != 1 {
+		t.Errorf("after reopening embedded %d texts, want only the new one", inner.texts)
+	}
+	if !slices.Equal(again[0], first[1]) || !slices.Equal(again[1], first[0]) {
+		t.Error("reopened cache returned other vectors")
+	}
+	if got, want := c.Stats(), (CacheStats{Hits: 2, Misses: 1, Entries: 3}); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+// TestCacheKeys checks that vectors are only served for the model, dimension and server that made them.
+func TestCacheKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, _ := openCache(t, dir, "m", 8)
+	if _, err := c.EmbedQuery(ctx, "to be"); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []struct {
+		model string
+		dim   int
+	}{{"m", 16}, {"other", 8}, {"m@http://localhost:8089/v1", 8}} {
+		c, inner := openCache(t, dir, k.model, k.dim)
+		if _, err := c.EmbedQuery(ctx, "to be"); err != nil {
+			t.Fatal(err)
+		}
+		if inner.texts != 1 {
+			t.Errorf("model %s with %d dimensions was served the vector of m with 8", k.model, k.dim)
+		}
+	}
+
+	tests := []struct {
+		backend, model string
+		dim            int
+		baseURL        string
+		wantModel      string
+		wantDim        int
+	}{
+		{BackendLocal, OpenAIEmbeddingModel, 512, "", "local-char-ngram-3-5", 512},
+		{BackendOpenAI, "text-embedding-3-large", 512, "", "text-embedding-3-large", 3072},
+		{BackendOpenAI, "text-embedding-3-small", 0, "", "text-embedding-3-small", 1536},
+		{BackendOpenAI, "text-embedding-3-large", 0, "http://localhost:8089/v1", "text-embedding-3-large@http://localhost:8089/v1", 0},
+	}
+	for _, tt := range tests {
+		model, dim := embedderModel(tt.backend, tt.model, tt.dim, tt.baseURL)
+		if model != tt.wantModel || dim != tt.wantDim {
+			t.Errorf("embedderModel(%s, %s, %d, %q) = %s, %d, want %s, %d", tt.backend, tt.model, tt.dim, tt.baseURL, model, dim, tt.wantModel, tt.wantDim)
+		}
+	}
+}
+
+// TestCacheWrongDimension drops records whose vectors don't have the dimension of their key.
+func TestCacheWrongDimension(t *testing.T) {
+	dir := t.TempDir()
+
+	c, _ := openCache(t, dir, "text-embedding-3-large", 3072)
+	if err := c.store([]string{"to be"}, [][]float32{{1, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ = openCache(t, dir, "text-embedding-3-large", 3072); c.Stats().Entries != 0 {
+		t.Errorf("loaded %d entries, want the 4 dimensional vector dropped", c.Stats().Entries)
+	}
+}
+
+func TestCacheTornRecord(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, cacheFileName)
+
+	c, _ := openCache(t, dir, "m", 8)
+	if _, err := c.EmbedQuery(ctx, "to be"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole := info.Size()
+	if _, err := c.EmbedQuery(ctx, "or not"); err != nil {
+		t.Fatal(err)
+	}
+	// A crash in the middle of the second record leaves part of it behind.
+	if err := os.Truncate(path, whole+10); err != nil {
+		t.Fatal(err)
+	}
+
+	c, inner := openCache(t, dir, "m", 8)
+	if c.Stats().Entries != 1 {
+		t.Errorf("loaded %d entries, want 1", c.Stats().Entries)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != whole {
+		t.Errorf("cache file is %d bytes, want the torn record cut off at %d", info.Size(), whole)
+	}
+
+	// New records append after the last whole one.
+	if _, err := c.EmbedDocuments(ctx, []string{"to be", "or not"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ = openCache(t, dir, "m", 8); c.Stats().Entries != 2 || inner.texts != 1 {
+		t.Errorf("loaded %d entries after the append, want 2", c.Stats().Entries)
+	}
+}
+
+func TestCacheOtherVersion(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, cacheFileName)
+
+	for _, content := range [][]byte{
+		binary.LittleEndian.AppendUint16([]byte(cacheMagic), cacheVersion-1),
+		[]byte("no header at all, like a version 1 file"),
+		[]byte("EM"),
+	} {
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if c, _ := openCache(t, dir, "m", 8); c.Stats().Entries != 0 {
+			t.Errorf("%q: loaded %d entries", content, c.Stats().Entries)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%q: the file of another version was kept", content)
+		}
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	a, _ := openCache(t, dir, "a", 8)
+	if _, err := a.EmbedDocuments(ctx, []string{"to be", "or not"}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := openCache(t, dir, "b", 8)
+	if _, err := b.EmbedDocuments(ctx, []string{"to be", "or not"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// misses reopens model on the cache file and counts the texts it has to embed again.
+	misses := func(model string, texts ...string) int {
+		t.Helper()
+		c, inner := openCache(t, dir, model, 8)
+		if _, err := c.EmbedDocuments(ctx, texts); err != nil {
+			t.Fatal(err)
+		}
+		return inner.texts
+	}
+
+	if err := b.Invalidate([]string{"to be"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := misses("b", "to be", "or not"); n != 1 {
+		t.Errorf("after Invalidate model b embedded %d texts, want 1", n)
+	}
+	if n := misses("a", "to be", "or not"); n != 0 {
+		t.Errorf("Invalidate of model b dropped %d texts of model a", n)
+	}
+
+	a, _ = openCache(t, dir, "a", 8)
+	if err := a.InvalidateModel(); err != nil {
+		t.Fatal(err)
+	}
+	if n := misses("a", "to be", "or not"); n != 2 {
+		t.Errorf("after InvalidateModel model a embedded %d texts, want 2", n)
+	}
+
+	c, _ := openCache(t, dir, "b", 8)
+	if err := c.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Stats().Entries != 0 {
+		t.Errorf("%d entries after Clear", c.Stats().Entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, cacheFileName)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Clear kept the cache file")
+	}
+}
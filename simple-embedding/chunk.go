@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chunking decides what a "document" is before embedding.
+// Too small and a query matches half a sentence of verse, too large and one vector has to stand for several ideas.
+// Every chunk keeps its byte offsets into the source so a match can always be traced back to the file.
+
+const (
+	ChunkLine      = "line"
+	ChunkWords     = "words"
+	ChunkSentence  = "sentence"
+	ChunkParagraph = "paragraph"
+	ChunkRecursive = "recursive"
+	ChunkSpeech    = "speech"
+)
+
+type Chunk struct {
+	Text string
+	// Start and End are byte offsets into the source, Text == source[Start:End].
+	Start int
+	End   int
+}
+
+type Chunker interface {
+	Chunk(source string) []Chunk
+}
+
+// newChunker returns the chunker for strategy. size and overlap are in words and only apply to the
+// word window (size and overlap) and recursive (size) strategies.
+//
+// Sizes count whitespace separated words, not model tokens: counting tokens needs the model's tokenizer and its
+// vocabulary files, which a local run doesn't have. English averages a little over one token per word, so 64 words
+// are roughly 85 tokens of text-embedding-3-large.
+func newChunker(strategy string, size, overlap int) (Chunker, error) {
+	switch strategy {
+	case ChunkLine:
+		return lineChunker{}, nil
+	case ChunkSentence:
+		return sentenceChunker{}, nil
+	case ChunkParagraph:
+		return paragraphChunker{}, nil
+	case ChunkSpeech:
+		return speechChunker{}, nil
+	case ChunkWords:
+		if size <= 0 {
+			return nil, fmt.Errorf("chunk size must be positive, got %d", size)
+		}
+		if overlap < 0 || overlap >= size {
+			return nil, fmt.Errorf("chunk overlap must be between 0 and the chunk size (%d), got %d", size, overlap)
+		}
+		return wordWindowChunker{size: size, overlap: overlap}, nil
+	case ChunkRecursive:
+		if size <= 0 {
+			return nil, fmt.Errorf("chunk size must be positive, got %d", size)
+		}
+		return recursiveChunker{size: size, separators: []string{"\n\n", "\n", ". ", "; ", ", ", " "}}, nil
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy %q (want %s, %s, %s, %s, %s or %s)",
+			strategy, ChunkLine, ChunkWords, ChunkSentence, ChunkParagraph, ChunkRecursive, ChunkSpeech)
+	}
+}
+
+// lineChunker makes every non-blank line its own chunk.
+type lineChunker struct{}
+
+func (lineChunker) Chunk(source string) []Chunk {
+	var chunks []Chunk
+	start := 0
+	for start < len(source) {
+		end := strings.IndexByte(source[start:], '\n')
+		if end < 0 {
+			end = len(source)
+		} else {
+			end += start
+		}
+		chunks = appendChunk(chunks, source, start, end)
+		start = end + 1
+	}
+
+	return chunks
+}
+
+// wordWindowChunker slides a window of size words over the source, each window sharing overlap words with the last.
+type wordWindowChunker struct {
+	size    int
+	overlap int
+}
+
+func (c wordWindowChunker) Chunk(source string) []Chunk {
+	words := wordSpans(source)
+
+	var chunks []Chunk
+	step := c.size - c.overlap
+	for i := 0; i < len(words); i += step {
+		end := min(i+c.size, len(words))
+		chunks = appendChunk(chunks, source, words[i].start, words[end-1].end)
+		if end == len(words) {
+			break
+		}
+	}
+
+	return chunks
+}
+
+// sentenceChunker ends a chunk after '.', '!' or '?' followed by whitespace.
+type sentenceChunker struct{}
+
+func (sentenceChunker) Chunk(source string) []Chunk {
+	var chunks []Chunk
+	start := 0
+	for i := 0; i < len(source); i++ {
+		switch source[i] {
+		case '.', '!', '?':
+			if i+1 == len(source) || isSpaceByte(source[i+1]) {
+				chunks = appendChunk(chunks, source, start, i+1)
+				start = i + 1
+			}
+		}
+	}
+	chunks = appendChunk(chunks, source, start, len(source))
+
+	return chunks
+}
+
+// paragraphChunker splits on blank lines.
+type paragraphChunker struct{}
+
+func (paragraphChunker) Chunk(source string) []Chunk {
+	var chunks []Chunk
+	start := 0
+	for _, gap := range blankLineSpans(source) {
+		chunks = appendChunk(chunks, source, start, gap.start)
+		start = gap.end
+	}
+	chunks = appendChunk(chunks, source, start, len(source))
+
+	return chunks
+}
+
+// recursiveChunker tries the coarsest separator first (paragraphs) and only falls back to finer ones
+// (lines, sentences, clauses, words) for pieces still over size words. Neighbouring pieces are
+// merged back together while they fit, so chunks end up as large as allowed along natural boundaries.
+type recursiveChunker struct {
+	size       int
+	separators []string
+}
+
+func (c recursiveChunker) Chunk(source string) []Chunk {
+	var chunks []Chunk
+	for _, s := range c.split(source, span{0, len(source)}, c.separators) {
+		chunks = appendChunk(chunks, source, s.start, s.end)
+	}
+
+	return chunks
+}
+
+func (c recursiveChunker) split(source string, s span, separators []string) []span {
+	if wordCount(source[s.start:s.end]) <= c.size {
+		return []span{s}
+	}
+
+	if len(separators) == 0 {
+		// Nothing natural left to split on, fall back to fixed windows of words.
+		var result []span
+		words := wordSpans(source[s.start:s.end])
+		for i := 0; i < len(words); i += c.size {
+			end := min(i+c.size, len(words))
+			result = append(result, span{s.start + words[i].start, s.start + words[end-1].end})
+		}
+		return result
+	}
+
+	sep, rest := separators[0], separators[1:]
+	pieces := splitAfter(source, s, sep)
+	if len(pieces) == 1 {
+		return c.split(source, s, rest)
+	}
+
+	var result []span
+	var current span
+	currentWords := 0
+	for _, piece := range pieces {
+		n := wordCount(source[piece.start:piece.end])
+		if n > c.size {
+			if currentWords > 0 {
+				result = append(result, current)
+				currentWords = 0
+			}
+			result = append(result, c.split(source, piece, rest)...)
+			continue
+		}
+		if currentWords > 0 && currentWords+n > c.size {
+			result = append(result, current)
+			currentWords = 0
+		}
+		if currentWords == 0 {
+			current = piece
+		} else {
+			current.end = piece.end
+		}
+		currentWords += n
+	}
+	if currentWords > 0 {
+		result = append(result, current)
+	}
+
+	return result
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+// splitAfter cuts s after every occurrence of sep, keeping the separator with the piece before it.
+func splitAfter(source string, s span, sep string) []span {
+	var pieces []span
+	start := s.start
+	for {
+		i := strings.Index(source[start:s.end], sep)
+		if i < 0 {
+			break
+		}
+		end := start + i + len(sep)
+		pieces = append(pieces, span{start, end})
+		start = end
+	}
+	if start < s.end {
+		pieces = append(pieces, span{start, s.end})
+	}
+
+	return pieces
+}
+
+// wordSpans returns the byte spans of the whitespace separated words in text.
+func wordSpans(text string) []span {
+	var words []span
+	start := -1
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, span{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, span{start, len(text)})
+	}
+
+	return words
+}
+
+func wordCount(text string) int {
+	return len(strings.Fields(text))
+}
+
+// blankLineSpans returns the spans of runs of whitespace that contain at least two newlines.
+func blankLineSpans(text string) []span {
+	var gaps []span
+	for i := 0; i < len(text); {
+		if !isSpaceByte(text[i]) {
+			i++
+			continue
+		}
+		j, newlines := i, 0
+		for j < len(text) && isSpaceByte(text[j]) {
+			if text[j] == '\n' {
+				newlines++
+			}
+			j++
+		}
+		if newlines >= 2 {
+			gaps = append(gaps, span{i, j})
+		}
+		i = j
+	}
+
+	return gaps
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// appendChunk trims surrounding whitespace from source[start:end] and appends it unless nothing is left.
+func appendChunk(chunks []Chunk, source string, start, end int) []Chunk {
+	for start < end {
+		r, size := utf8.DecodeRuneInString(source[start:end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		start += size
+	}
+	for end > start {
+		r, size := utf8.DecodeLastRuneInString(source[start:end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		end -= size
+	}
+	if start == end {
+		return chunks
+	}
+
+	return append(chunks, Chunk{Text: source[start:end], Start: start, End: end})
+}
+
+// displayText collapses the line breaks and indentation in a chunk so it prints on one line.
+func displayText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
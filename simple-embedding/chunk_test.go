@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// chunkStrategies are the strategies TestChunkOffsets runs over every corpus file.
+var chunkStrategies = []string{ChunkLine, ChunkWords, ChunkSentence, ChunkParagraph, ChunkRecursive}
+
+func readCorpora(t *testing.T) map[string]string {
+	t.Helper()
+
+	paths, err := filepath.Glob("corpora/*.txt")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no corpus files: %v", err)
+	}
+	sources := make(map[string]string)
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(path)] = string(b)
+	}
+
+	return sources
+}
+
+// TestChunkOffsets checks that every chunk is the trimmed, non-empty text between its offsets and that chunks
+// come in source order, overlapping only for word windows.
+func TestChunkOffsets(t *testing.T) {
+	sources := readCorpora(t)
+	sources["edge cases"] = "  \n\tOne line.\r\n\n\nÆsir — ünïcode words.  Two?\n\n  "
+	sources["empty"] = ""
+
+	for _, strategy := range chunkStrategies {
+		chunker, err := newChunker(strategy, 20, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, source := range sources {
+			chunks := chunker.Chunk(source)
+			if len(chunks) == 0 && strings.TrimSpace(source) != "" {
+				t.Errorf("%s on %s: no chunks", strategy, name)
+			}
+			prev := Chunk{}
+			for i, c := range chunks {
+				if c.Start < 0 || c.End > len(source) || c.Start >= c.End || c.Text != source[c.Start:c.End] {
+					t.Fatalf("%s on %s: chunk %d [%d, %d) is not its source text", strategy, name, i, c.Start, c.End)
+				}
+				if c.Text != strings.TrimFunc(c.Text, unicode.IsSpace) {
+					t.Errorf("%s on %s: chunk %d %q is not trimmed", strategy, name, i, c.Text)
+				}
+				if i > 0 && (c.Start <= prev.Start || (strategy != ChunkWords && c.Start < prev.End)) {
+					t.Errorf("%s on %s: chunk %d [%d, %d) is out of order after [%d, %d)", strategy, name, i, c.Start, c.End, prev.Start, prev.End)
+				}
+				prev = c
+			}
+		}
+	}
+}
+
+func chunkTexts(chunks []Chunk) []string {
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+
+	return texts
+}
+
+func TestChunkers(t *testing.T) {
+	tests := []struct {
+		strategy      string
+		size, overlap int
+		source        string
+		want          []string
+	}{
+		{ChunkLine, 0, 0, "To be,\n\n  or not to be:\nthat is the question", []string{"To be,", "or not to be:", "that is the question"}},
+		{ChunkWords, 3, 1, "a b c d e f g", []string{"a b c", "c d e", "e f g"}},
+		{ChunkWords, 3, 0, "a b\n c d e f g", []string{"a b\n c", "d e f", "g"}},
+		{ChunkWords, 5, 2, "a b", []string{"a b"}},
+		{ChunkSentence, 0, 0, "Who's there? Nay, answer me. Stand!\nLong live the King.", []string{"Who's there?", "Nay, answer me.", "Stand!", "Long live the King."}},
+		{ChunkSentence, 0, 0, "Mr.Smith said 3.14 is pi", []string{"Mr.Smith said 3.14 is pi"}},
+		{ChunkParagraph, 0, 0, "one\ntwo\n\n\nthree\n \nfour", []string{"one\ntwo", "three", "four"}},
+		{ChunkRecursive, 4, 0, "a b c.\n\nd e f g h i. j k", []string{"a b c.", "d e f g", "h i.", "j k"}},
+		{ChunkRecursive, 10, 0, "a b c.\n\nd e", []string{"a b c.\n\nd e"}},
+	}
+	for _, tt := range tests {
+		chunker, err := newChunker(tt.strategy, tt.size, tt.overlap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := chunkTexts(chunker.Chunk(tt.source)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s %d/%d on %q = %q, want %q", tt.strategy, tt.size, tt.overlap, tt.source, got, tt.want)
+		}
+	}
+}
+
+// TestRecursiveChunkSize checks that recursive chunks stay within the size and together hold every word.
+func TestRecursiveChunkSize(t *testing.T) {
+	for name, source := range readCorpora(t) {
+		chunker, err := newChunker(ChunkRecursive, 30, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var words []string
+		for _, c := range chunker.Chunk(source) {
+			if n := wordCount(c.Text); n > 30 {
+				t.Errorf("%s: chunk at %d has %d words", name, c.Start, n)
+			}
+			words = append(words, strings.Fields(c.Text)...)
+		}
+		if !slices.Equal(words, strings.Fields(source)) {
+			t.Errorf("%s: the chunks hold %d words, the source %d", name, len(words), len(strings.Fields(source)))
+		}
+	}
+}
+
+func TestNewChunkerErrors(t *testing.T) {
+	for _, tt := range []struct {
+		strategy      string
+		size, overlap int
+	}{
+		{"fixed", 10, 0},
+		{ChunkWords, 0, 0},
+		{ChunkWords, 10, 10},
+		{ChunkWords, 10, -1},
+		{ChunkRecursive, 0, 0},
+	} {
+		if _, err := newChunker(tt.strategy, tt.size, tt.overlap); err == nil {
+			t.Errorf("newChunker(%q, %d, %d) succeeded", tt.strategy, tt.size, tt.overlap)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Document is one file of the corpus, e.g. corpora/shakespeare-hamlet.txt.
+type Document struct {
+	Path string
+	// Name is the file name without its extension, e.g. "shakespeare-hamlet".
+	Name   string
+	Source string
+	Play   Play
+
+	lineStarts []int
+}
+
+// Passage is a chunk together with the document it came from.
+type Passage struct {
+	Chunk
+	Doc *Document
+}
+
+// loadCorpus reads every .txt file in a directory, or every file matching a glob like "corpora/*-h*.txt".
+func loadCorpus(pattern string) ([]*Document, error) {
+	var paths []string
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		paths, err = filepath.Glob(filepath.Join(pattern, "*.txt"))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		paths, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad corpus pattern %q: %w", pattern, err)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no corpus files found at %q", pattern)
+	}
+	sort.Strings(paths)
+
+	docs := make([]*Document, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		docs = append(docs, newDocument(path, string(content)))
+	}
+
+	return docs, nil
+}
+
+func newDocument(path, source string) *Document {
+	doc := &Document{
+		Path:       path,
+		Name:       strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Source:     source,
+		Play:       parsePlay(source),
+		lineStarts: []int{0},
+	}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			doc.lineStarts = append(doc.lineStarts, i+1)
+		}
+	}
+
+	return doc
+}
+
+// Title is the play's title when the file has one, otherwise the file name.
+func (d *Document) Title() string {
+	if d.Play.Title != "" {
+		return d.Play.Title
+	}
+
+	return d.Name
+}
+
+// lineAt returns the 1-based line number containing the byte offset.
+func (d *Document) lineAt(offset int) int {
+	return sort.Search(len(d.lineStarts), func(i int) bool {
+		return d.lineStarts[i] > offset
+	})
+}
+
+// selectDocuments keeps the documents named in a comma separated list, matching case-insensitively
+// against the file name or play title, so "hamlet,caesar" and "shakespeare-macbeth" both work.
+// An empty list keeps everything.
+func selectDocuments(docs []*Document, only string) ([]*Document, error) {
+	if strings.TrimSpace(only) == "" {
+		return docs, nil
+	}
+
+	var selected []*Document
+	for _, want := range strings.Split(only, ",") {
+		want = strings.ToLower(strings.TrimSpace(want))
+		if want == "" {
+			continue
+		}
+
+		found := false
+		for _, doc := range docs {
+			if strings.Contains(strings.ToLower(doc.Name), want) || strings.Contains(strings.ToLower(doc.Title()), want) {
+				if !containsDocument(selected, doc) {
+					selected = append(selected, doc)
+				}
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no corpus document matches %q", want)
+		}
+	}
+
+	return selected, nil
+}
+
+func containsDocument(docs []*Document, doc *Document) bool {
+	for _, d := range docs {
+		if d == doc {
+			return true
+		}
+	}
+
+	return false
+}
+
+// chunkCorpus chunks every document and keeps track of where each chunk came from.
+func chunkCorpus(docs []*Document, chunker Chunker) []Passage {
+	var passages []Passage
+	for _, doc := range docs {
+		for _, chunk := range chunker.Chunk(doc.Source) {
+			passages = append(passages, Passage{Chunk: chunk, Doc: doc})
+		}
+	}
+
+	return passages
+}
+
+// Line is the 1-based line the passage starts on.
+func (p Passage) Line() int {
+	return p.Doc.lineAt(p.Start)
+}
+
+// Label names the source of a passage, e.g. "Macbeth, line 876",
+// or "Macbeth, line 876, Act II Scene 2, Macbeth speaking" when it falls inside a speech.
+// A speech before the first act heading has no act or scene to name.
+func (p Passage) Label() string {
+	label := fmt.Sprintf("%s, line %d", p.Doc.Title(), p.Line())
+	if speech, ok := p.Doc.Play.speechAt(p.Start, p.End); ok {
+		if speech.Act > 0 {
+			label += fmt.Sprintf(", Act %s Scene %d", romanNumeral(speech.Act), speech.Scene)
+		}
+		label += fmt.Sprintf(", %s speaking", speech.speakerName())
+	}
+
+	return label
+}
+
+func passageTexts(passages []Passage) []string {
+	texts := make([]string, 0, len(passages))
+	for _, p := range passages {
+		texts = append(texts, p.Text)
+	}
+
+	return texts
+}
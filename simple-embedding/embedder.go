@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/quinn-collins/embedding-server/hashing"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// Embedder turns text into vectors.
+// The OpenAI backed openAIEmbedder satisfies it, and so does localEmbedder which needs no network.
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+const (
+	BackendOpenAI = "openai"
+	BackendLocal  = "local"
+
+	OpenAIEmbeddingModel = "text-embedding-3-large"
+)
+
+// getEmbedder returns the embedder for the named backend.
+// baseURL only applies to the OpenAI backend and may point at any OpenAI compatible server, e.g. ../embedding-server.
+func getEmbedder(backend, model string, dim int, baseURL string) Embedder {
+	switch backend {
+	case BackendOpenAI:
+		return getOpenAIEmbedder(model, baseURL)
+	case BackendLocal:
+		return newLocalEmbedder(dim)
+	default:
+		log.Fatalf("unknown embedding backend %q (want %q or %q)", backend, BackendOpenAI, BackendLocal)
+	}
+
+	return nil
+}
+
+// openAIEmbeddingDims are the dimensions the OpenAI embedding models return by default.
+var openAIEmbeddingDims = map[string]int{
+	"text-embedding-3-large": 3072,
+	"text-embedding-3-small": 1536,
+	"text-embedding-ada-002": 1536,
+}
+
+// embedderModel identifies the model behind a backend for cache keys, together with the dimension of its vectors,
+// 0 when it isn't known up front.
+// A compatible server given with -base-url returns its own vectors under the OpenAI model names, so the base URL
+// is part of the name and they are never served as OpenAI's.
+func embedderModel(backend, model string, dim int, baseURL string) (string, int) {
+	if backend == BackendLocal {
+		return fmt.Sprintf("local-char-ngram-%d-%d", localMinN, localMaxN), dim
+	}
+	if baseURL != "" {
+		return model + "@" + baseURL, 0
+	}
+
+	return model, openAIEmbeddingDims[model]
+}
+
+func getOpenAIEmbedder(model, baseURL string) *openAIEmbedder {
+	if os.Getenv("OPENAI_API_KEY") == "" {
+		log.Fatalf("OPEN_API_KEY environment variable not set")
+	}
+
+	// WithModel only picks the chat model, embeddings use their own setting.
+	opts := []openai.Option{
+		openai.WithModel(model),
+		openai.WithEmbeddingModel(model),
+		openai.WithHTTPClient(statusRecordingClient{client: http.DefaultClient}),
+	}
+	if baseURL != "" {
+		opts = append(opts, openai.WithBaseURL(baseURL))
+	}
+
+	// openai.New automatically checks OPENAI_API_KEY env var
+	llm, err := openai.New(opts...)
+	if err != nil {
+		log.Fatalf("failed to create OpenAI client: %v", err)
+	}
+
+	embedder, err := embeddings.NewEmbedder(llm)
+	if err != nil {
+		log.Fatalf("failed to create an OpenAI embedding model: %v", err)
+	}
+
+	return &openAIEmbedder{inner: embedder}
+}
+
+// StatusError is an API call the server answered with a status other than 200.
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// openAIEmbedder gives the errors of the langchaingo embedder back their cause. langchaingo only reports the
+// status of a failed call in its error text and replaces network errors with messages of its own, so the HTTP
+// client records what happened to every request in a requestOutcome carried by the request's context.
+type openAIEmbedder struct {
+	inner *embeddings.EmbedderImpl
+}
+
+type requestOutcomeKey struct{}
+
+type requestOutcome struct {
+	mu     sync.Mutex
+	status int
+	err    error
+}
+
+func (e *openAIEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	outcome := &requestOutcome{}
+	vectors, err := e.inner.EmbedDocuments(context.WithValue(ctx, requestOutcomeKey{}, outcome), texts)
+	if err != nil {
+		return nil, outcome.wrap(err)
+	}
+
+	return vectors, nil
+}
+
+func (e *openAIEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	outcome := &requestOutcome{}
+	vector, err := e.inner.EmbedQuery(context.WithValue(ctx, requestOutcomeKey{}, outcome), text)
+	if err != nil {
+		return nil, outcome.wrap(err)
+	}
+
+	return vector, nil
+}
+
+// wrap adds the status or the network error of the request that failed to err.
+func (o *requestOutcome) wrap(err error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch {
+	case o.status != 0:
+		return &StatusError{Code: o.status, Err: err}
+	case o.err != nil:
+		return fmt.Errorf("%w: %w", err, o.err)
+	}
+
+	return err
+}
+
+// statusRecordingClient is the HTTP client of the langchaingo client, it fills in the requestOutcome of a request.
+type statusRecordingClient struct {
+	client *http.Client
+}
+
+func (c statusRecordingClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+
+	if outcome, ok := req.Context().Value(requestOutcomeKey{}).(*requestOutcome); ok {
+		outcome.mu.Lock()
+		switch {
+		case err != nil:
+			outcome.err = err
+		case resp.StatusCode != http.StatusOK:
+			outcome.status = resp.StatusCode
+		}
+		outcome.mu.Unlock()
+	}
+
+	return resp, err
+}
+
+// localEmbedder is a deterministic, offline embedder: hashing.Embed over character n-grams of every word.
+// ../embedding-server returns the same vectors, so the two backends agree when their dimensions do.
+const (
+	localMinN = 3
+	localMaxN = 5
+)
+
+type localEmbedder struct {
+	dim  int
+	minN int
+	maxN int
+}
+
+func newLocalEmbedder(dim int) *localEmbedder {
+	if dim <= 0 {
+		log.Fatalf("local embedder dimension must be positive, got %d", dim)
+	}
+
+	return &localEmbedder{
+		dim:  dim,
+		minN: localMinN,
+		maxN: localMaxN,
+	}
+}
+
+func (e *localEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	result := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result = append(result, e.embed(text))
+	}
+
+	return result, nil
+}
+
+func (e *localEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return e.embed(text), nil
+}
+
+func (e *localEmbedder) embed(s string) []float32 {
+	return hashing.Embed(s, e.dim, e.minN, e.maxN)
+}
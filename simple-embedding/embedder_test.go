@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/quinn-collins/embedding-server/server"
+)
+
+// startEmbeddingServer runs ../embedding-server in the test and returns the OpenAI embedder pointed at it.
+func startEmbeddingServer(t *testing.T, dim int) (*server.Server, Embedder) {
+	t.Helper()
+
+	s := server.New(dim, "test-key", 1)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	return s, getOpenAIEmbedder(OpenAIEmbeddingModel, ts.URL+"/v1")
+}
+
+func TestOpenAIEmbedderAgainstServer(t *testing.T) {
+	_, embedder := startEmbeddingServer(t, 64)
+	ctx := context.Background()
+
+	texts := []string{"To be, or not to be", "Is this a dagger which I see before me"}
+	vecs, err := embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server and the local backend compute the same vectors.
+	local := newLocalEmbedder(64)
+	for i, text := range texts {
+		if !slices.Equal(vecs[i], local.embed(text)) {
+			t.Errorf("vector of %q differs from the local backend", text)
+		}
+	}
+
+	query, err := embedder.EmbedQuery(ctx, texts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(query, vecs[1]) {
+		t.Error("EmbedQuery and EmbedDocuments disagree")
+	}
+}
+
+func TestOpenAIEmbedderFaults(t *testing.T) {
+	tests := []struct {
+		name       string
+		faults     server.Faults
+		wantStatus int
+		wantRetry  bool
+	}{
+		{"throttled", server.Faults{ThrottleEvery: 1}, http.StatusTooManyRequests, true},
+		{"failing", server.Faults{FailRate: 1}, http.StatusInternalServerError, true},
+		{"slow", server.Faults{Latency: server.Duration(time.Second)}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, embedder := startEmbeddingServer(t, 8)
+			if err := s.SetFaults(tt.faults); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			vecs, err := embedder.EmbedDocuments(ctx, []string{"to sleep"})
+			if err == nil {
+				t.Fatalf("got %d vectors, want an error", len(vecs))
+			}
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) != (tt.wantStatus != 0) || (statusErr != nil && statusErr.Code != tt.wantStatus) {
+				t.Errorf("error %v, want status %d", err, tt.wantStatus)
+			}
+			if isRetryable(err) != tt.wantRetry {
+				t.Errorf("isRetryable(%v) = %t, want %t", err, !tt.wantRetry, tt.wantRetry)
+			}
+
+			// Without faults the same embedder works again.
+			if err := s.SetFaults(server.Faults{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := embedder.EmbedQuery(context.Background(), "to sleep"); err != nil {
+				t.Errorf("after clearing the faults: %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenAIEmbedderWrongKey(t *testing.T) {
+	ts := httptest.NewServer(server.New(8, "other-key", 1).Handler())
+	defer ts.Close()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	embedder := getOpenAIEmbedder(OpenAIEmbeddingModel, ts.URL+"/v1")
+	_, err := embedder.EmbedQuery(context.Background(), "to sleep")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusUnauthorized || isRetryable(err) {
+		t.Errorf("got %v, want a 401 that is not retried", err)
+	}
+}
+
+func TestOpenAIEmbedderConnectionRefused(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+	t.Setenv("OPENAI_API_KEY", "test-key")
+
+	_, err := getOpenAIEmbedder(OpenAIEmbeddingModel, url+"/v1").EmbedQuery(context.Background(), "to sleep")
+	if !errors.Is(err, syscall.ECONNREFUSED) || !isRetryable(err) {
+		t.Errorf("got %v, want a refused connection that is retried", err)
+	}
+}
+
+// TestBatchingOverThrottledServer retries every other request the server throttles.
+func TestBatchingOverThrottledServer(t *testing.T) {
+	s, embedder := startEmbeddingServer(t, 8)
+	if err := s.SetFaults(server.Faults{ThrottleEvery: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := defaultBatchOptions()
+	opts.MaxBatchSize = 1
+	opts.BaseDelay, opts.MaxDelay = time.Millisecond, 5*time.Millisecond
+	texts := []string{"to be", "or not", "to be", "that is", "the question"}
+	vecs, err := newBatchingEmbedder(embedder, opts).EmbedDocuments(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != len(texts) || !slices.Equal(vecs[0], vecs[2]) {
+		t.Errorf("got %d vectors, want %d with the repeated text embedded alike", len(vecs), len(texts))
+	}
+}
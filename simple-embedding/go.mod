@@ -2,10 +2,15 @@ module github.com/quinn-collins/simple-embedding
 
 go 1.25.6
 
-require github.com/tmc/langchaingo v0.1.14
+require (
+	github.com/quinn-collins/embedding-server v0.0.0
+	github.com/tmc/langchaingo v0.1.14
+)
 
 require (
 	github.com/dlclark/regexp2 v1.10.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/pkoukk/tiktoken-go v0.1.6 // indirect
 )
+
+replace github.com/quinn-collins/embedding-server => ../embedding-server
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// HNSW is a Hierarchical Navigable Small World graph (Malkov and Yashunin, 2016) for approximate nearest
+// neighbour search. Every vector is a node on layer 0 and, with exponentially falling probability, on the
+// layers above it. A search walks greedily down the sparse upper layers to find a good starting point, then
+// runs a best-first search of width efSearch on layer 0, so a query touches a few thousand vectors instead of all.
+
+type Metric int
+
+const (
+	MetricCosine Metric = iota
+	MetricDot
+	MetricEuclid
+)
+
+func (m Metric) String() string {
+	switch m {
+	case MetricCosine:
+		return "cosine"
+	case MetricDot:
+		return "dot"
+	case MetricEuclid:
+		return "euclid"
+	default:
+		return fmt.Sprintf("Metric(%d)", int(m))
+	}
+}
+
+func parseMetric(name string) (Metric, error) {
+	for _, m := range []Metric{MetricCosine, MetricDot, MetricEuclid} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown metric %q (want cosine, dot or euclid)", name)
+}
+
+type HNSWOptions struct {
+	// M is the number of links a node keeps on each upper layer, layer 0 keeps 2*M.
+	// More links mean better recall and more memory and distance computations per hop.
+	M int
+	// EfConstruction is the search width used to find the neighbours of a new node.
+	EfConstruction int
+	// EfSearch is the search width at query time, it is raised to k when k is larger.
+	EfSearch int
+	Metric   Metric
+	// Seed makes the layer assignment and so the whole graph reproducible.
+	Seed int64
+}
+
+func defaultHNSWOptions() HNSWOptions {
+	return HNSWOptions{
+		M:              16,
+		EfConstruction: 200,
+		EfSearch:       64,
+		Metric:         MetricCosine,
+		Seed:           1,
+	}
+}
+
+type hnswNode struct {
+	// id is the caller's id, e.g. a passage index.
+	id      int
+	vector  []float32
+	links   [][]int
+	deleted bool
+}
+
+// HNSW is safe for concurrent use, searches run in parallel with each other but not with inserts and deletes.
+type HNSW struct {
+	mu        sync.RWMutex
+	opts      HNSWOptions
+	levelMult float64
+	rng       *rand.Rand
+	dim       int
+	nodes     []*hnswNode
+	ids       map[int]int
+	entry     int
+	maxLevel  int
+}
+
+func newHNSW(opts HNSWOptions) (*HNSW, error) {
+	if opts.M < 2 {
+		return nil, fmt.Errorf("HNSW M must be at least 2, got %d", opts.M)
+	}
+	if opts.EfConstruction < 1 || opts.EfSearch < 1 {
+		return nil, fmt.Errorf("HNSW efConstruction and efSearch must be positive, got %d and %d", opts.EfConstruction, opts.EfSearch)
+	}
+
+	return &HNSW{
+		opts:      opts,
+		levelMult: 1 / math.Log(float64(opts.M)),
+		rng:       rand.New(rand.NewSource(opts.Seed)),
+		ids:       make(map[int]int),
+		entry:     -1,
+	}, nil
+}
+
+// buildHNSW indexes every vector under its position in vectors, skipping nil vectors that were never embedded.
+func buildHNSW(vectors [][]float32, opts HNSWOptions) (*HNSW, error) {
+	index, err := newHNSW(opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vectors {
+		if v == nil {
+			continue
+		}
+		if err := index.Insert(i, v); err != nil {
+			return nil, fmt.Errorf("vector %d: %w", i, err)
+		}
+	}
+
+	return index, nil
+}
+
+// Len returns the number of vectors that have not been deleted.
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.ids)
+}
+
+func (h *HNSW) SetEfSearch(ef int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.opts.EfSearch = max(1, ef)
+}
+
+// errZeroVector is only returned by Insert, searching for a zero vector finds nothing.
+var errZeroVector = errors.New("cannot index a zero vector by cosine similarity")
+
+// prepare copies v, normalizing it for cosine so that cosine distance is one minus a dot product.
+func (h *HNSW) prepare(v []float32) ([]float32, error) {
+	if h.dim != 0 && len(v) != h.dim {
+		return nil, fmt.Errorf("vector has %d dimensions, the index has %d", len(v), h.dim)
+	}
+
+	out := append([]float32(nil), v...)
+	if h.opts.Metric == MetricCosine {
+		n := norm32(out)
+		if n == 0 {
+			return nil, errZeroVector
+		}
+		for i := range out {
+			out[i] /= n
+		}
+	}
+
+	return out, nil
+}
+
+// distance is smaller for closer vectors whatever the metric: 1-cos, -dot or the squared euclidean distance.
+func (h *HNSW) distance(a, b []float32) float32 {
+	switch h.opts.Metric {
+	case MetricDot:
+		return -dot32(a, b)
+	case MetricEuclid:
+		var sum float32
+		for i := range a {
+			d := a[i] - b[i]
+			sum += d * d
+		}
+		return sum
+	default:
+		return 1 - dot32(a, b)
+	}
+}
+
+// score turns a distance back into a Match score where higher is closer.
+// Cosine and dot give the similarity itself, euclid gives the negated distance.
+func (h *HNSW) score(distance float32) float64 {
+	switch h.opts.Metric {
+	case MetricDot:
+		return float64(-distance)
+	case MetricEuclid:
+		return -math.Sqrt(float64(distance))
+	default:
+		return float64(1 - distance)
+	}
+}
+
+func (h *HNSW) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.opts.M
+	}
+
+	return h.opts.M
+}
+
+// Insert adds vector under id. Inserting an id that is already present replaces its vector.
+func (h *HNSW) Insert(id int, vector []float32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, err := h.prepare(vector)
+	if err != nil {
+		return err
+	}
+	if h.dim == 0 {
+		h.dim = len(v)
+	}
+	if old, ok := h.ids[id]; ok {
+		h.nodes[old].deleted = true
+	}
+
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+	node := len(h.nodes)
+	h.nodes = append(h.nodes, &hnswNode{id: id, vector: v, links: make([][]int, level+1)})
+	h.ids[id] = node
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = node, level
+		return nil
+	}
+
+	entries := []candidate{{node: h.entry, dist: h.distance(v, h.nodes[h.entry].vector)}}
+	for l := h.maxLevel; l > level; l-- {
+		entries = h.searchLayer(v, entries, 1, l, false)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(v, entries, h.opts.EfConstruction, l, false)
+		for _, c := range h.selectNeighbors(found, h.opts.M) {
+			h.nodes[node].links[l] = append(h.nodes[node].links[l], c.node)
+			h.link(c.node, node, l)
+		}
+		entries = found
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = node, level
+	}
+
+	return nil
+}
+
+// link adds a link from node to neighbour on level, pruning node's links back down to the limit if needed.
+func (h *HNSW) link(node, neighbour, level int) {
+	n := h.nodes[node]
+	n.links[level] = append(n.links[level], neighbour)
+	if len(n.links[level]) <= h.maxLinks(level) {
+		return
+	}
+
+	cands := make([]candidate, 0, len(n.links[level]))
+	for _, other := range n.links[level] {
+		cands = append(cands, candidate{node: other, dist: h.distance(n.vector, h.nodes[other].vector)})
+	}
+	sortCandidates(cands)
+
+	kept := h.selectNeighbors(cands, h.maxLinks(level))
+	n.links[level] = n.links[level][:0]
+	for _, c := range kept {
+		n.links[level] = append(n.links[level], c.node)
+	}
+}
+
+// selectNeighbors picks up to m of the candidates, nearest first, with the paper's heuristic: a candidate is
+// skipped when it is closer to an already chosen neighbour than to the base, which keeps links spread out in
+// different directions instead of all pointing into one cluster. Skipped candidates fill any remaining slots.
+func (h *HNSW) selectNeighbors(cands []candidate, m int) []candidate {
+	selected := make([]candidate, 0, m)
+	var skipped []candidate
+	for _, c := range cands {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if h.distance(h.nodes[c.node].vector, h.nodes[s.node].vector) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, c)
+	}
+
+	return selected
+}
+
+// Delete removes id from search results and reports whether it was present. The node stays in the graph as a
+// tombstone so the links through it keep working, rebuild the index if a large share of it has been deleted.
+func (h *HNSW) Delete(id int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	node, ok := h.ids[id]
+	if !ok {
+		return false
+	}
+	h.nodes[node].deleted = true
+	delete(h.ids, id)
+
+	return true
+}
+
+// Search returns the k indexed vectors closest to query, best first, with Match.Index set to the inserted id.
+func (h *HNSW) Search(query []float32, k int) ([]Match, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.ids) == 0 || k <= 0 {
+		return nil, nil
+	}
+	q, err := h.prepare(query)
+	if errors.Is(err, errZeroVector) {
+		// Nothing is similar to a zero vector, the same as the linear scan finds.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []candidate{{node: h.entry, dist: h.distance(q, h.nodes[h.entry].vector)}}
+	for l := h.maxLevel; l > 0; l-- {
+		entries = h.searchLayer(q, entries, 1, l, false)
+	}
+	found := h.searchLayer(q, entries, max(h.opts.EfSearch, k), 0, true)
+
+	matches := make([]Match, 0, min(k, len(found)))
+	for _, c := range found[:min(k, len(found))] {
+		matches = append(matches, Match{Index: h.nodes[c.node].id, Score: h.score(c.dist)})
+	}
+
+	return matches, nil
+}
+
+// exactSearch scores every live vector with the index's own metric, it is the ground truth for recall.
+func (h *HNSW) exactSearch(query []float32, k int) ([]Match, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	q, err := h.prepare(query)
+	if errors.Is(err, errZeroVector) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	best := make(matchHeap, 0, k)
+	for _, node := range h.ids {
+		n := h.nodes[node]
+		best.offer(Match{Index: n.id, Score: h.score(h.distance(q, n.vector))}, k)
+	}
+
+	return best.sorted(), nil
+}
+
+type candidate struct {
+	node int
+	dist float32
+}
+
+func sortCandidates(cands []candidate) {
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].dist != cands[j].dist {
+			return cands[i].dist < cands[j].dist
+		}
+		return cands[i].node < cands[j].node
+	})
+}
+
+// candidateHeap is a min-heap on distance, or a max-heap when farthestFirst is set.
+type candidateHeap struct {
+	items         []candidate
+	farthestFirst bool
+}
+
+func (c candidateHeap) Len() int { return len(c.items) }
+func (c candidateHeap) Less(i, j int) bool {
+	if c.farthestFirst {
+		return c.items[i].dist > c.items[j].dist
+	}
+	return c.items[i].dist < c.items[j].dist
+}
+func (c candidateHeap) Swap(i, j int) { c.items[i], c.items[j] = c.items[j], c.items[i] }
+func (c *candidateHeap) Push(x any)   { c.items = append(c.items, x.(candidate)) }
+func (c *candidateHeap) Pop() any {
+	old := c.items
+	item := old[len(old)-1]
+	c.items = old[:len(old)-1]
+	return item
+}
+
+// searchLayer is a best-first search of one layer from entries that returns up to ef nodes nearest first.
+// Deleted nodes are always walked through and are left out of the results when skipDeleted is set.
+func (h *HNSW) searchLayer(q []float32, entries []candidate, ef, level int, skipDeleted bool) []candidate {
+	visited := make([]uint64, (len(h.nodes)+63)/64)
+	next := &candidateHeap{}
+	results := &candidateHeap{farthestFirst: true}
+	for _, e := range entries {
+		visited[e.node/64] |= 1 << (e.node % 64)
+		heap.Push(next, e)
+		if !skipDeleted || !h.nodes[e.node].deleted {
+			heap.Push(results, e)
+		}
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+
+	for next.Len() > 0 {
+		c := heap.Pop(next).(candidate)
+		if results.Len() >= ef && c.dist > results.items[0].dist {
+			break
+		}
+		for _, n := range h.nodes[c.node].links[level] {
+			if visited[n/64]&(1<<(n%64)) != 0 {
+				continue
+			}
+			visited[n/64] |= 1 << (n % 64)
+
+			d := h.distance(q, h.nodes[n].vector)
+			if results.Len() < ef || d < results.items[0].dist {
+				heap.Push(next, candidate{node: n, dist: d})
+				if !skipDeleted || !h.nodes[n].deleted {
+					heap.Push(results, candidate{node: n, dist: d})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+
+	found := results.items
+	sortCandidates(found)
+
+	return found
+}
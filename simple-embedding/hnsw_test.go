@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestHNSWZeroVector(t *testing.T) {
+	index, err := buildHNSW([][]float32{{1, 0}, {0, 1}}, defaultHNSWOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := index.Insert(2, []float32{0, 0}); !errors.Is(err, errZeroVector) {
+		t.Errorf("Insert of a zero vector: got %v, want %v", err, errZeroVector)
+	}
+
+	matches, err := index.Search([]float32{0, 0}, 2)
+	if err != nil || len(matches) != 0 {
+		t.Errorf("Search for a zero vector: got %v, %v, want no matches", matches, err)
+	}
+	if got := topKCosine([]float32{0, 0}, [][]float32{{1, 0}, {0, 1}}, []float32{1, 1}, 2, 1); len(got) != 0 {
+		t.Errorf("linear scan for a zero vector: got %v, want no matches", got)
+	}
+}
+
+// randomVectors returns n reproducible vectors with components in [-1, 1).
+func randomVectors(n, dim int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.Float32()*2 - 1
+		}
+	}
+
+	return vectors
+}
+
+// recall returns the share of the exact top k of each query that index.Search finds.
+func recall(t *testing.T, index *HNSW, queries [][]float32, k int) float64 {
+	t.Helper()
+
+	exact, approx := make([][]Match, len(queries)), make([][]Match, len(queries))
+	for i, q := range queries {
+		var err error
+		if exact[i], err = index.exactSearch(q, k); err != nil {
+			t.Fatal(err)
+		}
+		if approx[i], err = index.Search(q, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return recallAtK(exact, approx)
+}
+
+func TestHNSWRecall(t *testing.T) {
+	vectors := randomVectors(2000, 24, 1)
+	queries := randomVectors(50, 24, 2)
+
+	for _, metric := range []Metric{MetricCosine, MetricDot, MetricEuclid} {
+		opts := defaultHNSWOptions()
+		opts.Metric = metric
+		index, err := buildHNSW(vectors, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := recall(t, index, queries, 10); r < 0.95 {
+			t.Errorf("%s: recall@10 is %.3f, want at least 0.95", metric, r)
+		}
+
+		// Scores come best first and agree with the exact search on the best match.
+		got, _ := index.Search(queries[0], 10)
+		want, _ := index.exactSearch(queries[0], 10)
+		for i := 1; i < len(got); i++ {
+			if got[i].Score > got[i-1].Score {
+				t.Errorf("%s: match %d scores %v after %v", metric, i, got[i].Score, got[i-1].Score)
+			}
+		}
+		if len(got) != 10 || got[0] != want[0] {
+			t.Errorf("%s: best match %v, want %v", metric, got[0], want[0])
+		}
+	}
+}
+
+func TestHNSWDelete(t *testing.T) {
+	vectors := randomVectors(1000, 16, 3)
+	index, err := buildHNSW(vectors, defaultHNSWOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := make(map[int]bool)
+	for id := 0; id < len(vectors); id += 3 {
+		if !index.Delete(id) {
+			t.Fatalf("Delete(%d) found nothing", id)
+		}
+		deleted[id] = true
+	}
+	if index.Delete(0) {
+		t.Error("deleting id 0 twice succeeded")
+	}
+	if want := len(vectors) - len(deleted); index.Len() != want {
+		t.Errorf("Len = %d after deleting, want %d", index.Len(), want)
+	}
+
+	// The deleted vectors themselves are the best matches they could be.
+	for id := range deleted {
+		matches, err := index.Search(vectors[id], 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 20 {
+			t.Errorf("search for deleted vector %d returned %d matches, want 20", id, len(matches))
+		}
+		for _, m := range matches {
+			if deleted[m.Index] {
+				t.Fatalf("search for vector %d returned deleted id %d", id, m.Index)
+			}
+		}
+	}
+	if r := recall(t, index, randomVectors(50, 16, 4), 10); r < 0.95 {
+		t.Errorf("recall@10 after deleting a third is %.3f, want at least 0.95", r)
+	}
+}
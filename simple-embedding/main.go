@@ -2,15 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"math"
 	"os"
-	"sort"
-	"strings"
-
-	"github.com/tmc/langchaingo/embeddings"
-	"github.com/tmc/langchaingo/llms/openai"
+	"time"
 )
 
 // Word2Vec was a successful vectorization algorithm, you can download other peoples vectors that have used this vectorization such as google news.
@@ -23,162 +20,235 @@ import (
 //	   'glove-twitter-100', 'glove-twitter-200',
 //	   '__testing_word2vec-matrix-synopsis']
 
+// Usage:
+//	go run . -query "Will the ocean clean this blood?" -k 10
+//	go run . -embedder local -only macbeth -chunker speech -i
+//	go run . -embedder local -index hnsw -hnsw-ef-search 128 -i
+//
+// Some queries against Macbeth with line chunks and text-embedding-3-large:
+//	"When should we get together again?"
+//		1) score=0.9036 |   1. When shall we three meet againe?
+//		2) score=0.8353 | Our point of second meeting.
+//		3) score=0.8308 | When shalt thou see thy wholsome dayes againe?
+//		4) score=0.8297 | Was it not yesterday we spoke together?
+//		5) score=0.8135 | Shall we well meet them, that way are they comming
+//	"Will the ocean clean this blood?"
+//		1) score=0.9032 | Will all great Neptunes Ocean wash this blood
+//		2) score=0.8319 | What will these hands ne're be cleane? No more o'that
+//		3) score=0.8283 | A little Water cleares vs of this deed.
+//		4) score=0.8155 | Blood will haue Blood:
+//		5) score=0.8096 | Cleanse the stufft bosome, of that perillous stuffe
+//	"I thought I heard someone yell, 'No more sleep'"
+//		1) score=0.8607 |    Macb. Me thought I heard a voyce cry, Sleep no more:
+//		2) score=0.8349 | Shall sleepe no more: Macbeth shall sleepe no more
+//		3) score=0.8306 | And yet I would not sleepe:
+//		4) score=0.8285 | Sleepe shall neyther Night nor Day
+//		5) score=0.8259 | Hath rung Nights yawning Peale,
+
+const defaultQuery = "I thought I heard someone yell, 'No more sleep'"
+
+const (
+	IndexLinear = "linear"
+	IndexHNSW   = "hnsw"
+)
+
 type Match struct {
 	Index int
 	Score float64
 }
 
+// Searcher holds a chunked and embedded corpus so it can answer many queries for the cost of embedding it once.
+type Searcher struct {
+	embedder   Embedder
+	passages   []Passage
+	embeddings [][]float32
+	norms      []float32
+	// index, when set, answers searches approximately instead of scanning every embedding.
+	index *HNSW
+}
+
 func main() {
+	query := flag.String("query", defaultQuery, "text to search the corpus for")
+	interactive := flag.Bool("i", false, "embed the corpus once and read queries from stdin")
+	topK := flag.Int("k", 5, "number of matches to print")
+	format := flag.String("format", FormatText, "output format: text, tsv, json or jsonl")
+	indexKind := flag.String("index", IndexLinear, "search index: linear (exact) or hnsw (approximate)")
+	hnswM := flag.Int("hnsw-m", 16, "links per HNSW node, layer 0 keeps twice as many")
+	hnswEfConstruction := flag.Int("hnsw-ef-construction", 200, "HNSW search width while inserting")
+	hnswEfSearch := flag.Int("hnsw-ef-search", 64, "HNSW search width while querying")
+	hnswMetric := flag.String("hnsw-metric", MetricCosine.String(), "HNSW distance: cosine, dot or euclid")
+	verbose := flag.Bool("verbose", false, "print the leading dimensions of every embedding")
+	backend := flag.String("embedder", BackendOpenAI, "embedding backend: openai or local (offline, no API key needed)")
+	model := flag.String("model", OpenAIEmbeddingModel, "OpenAI embedding model")
+	dim := flag.Int("dim", 512, "vector dimension for the local embedding backend")
+	corpus := flag.String("corpus", "./corpora", "directory of .txt files or a glob of files to search")
+	only := flag.String("only", "", "comma separated documents to search, matched against file names and play titles, e.g. hamlet,macbeth")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "directory for the on-disk embedding cache, empty disables caching")
+	cacheClear := flag.Bool("cache-clear", false, "drop every cached vector before running")
+	cacheInvalidate := flag.Bool("cache-invalidate", false, "drop cached vectors for the selected model and dimension before running")
+	chunkStrategy := flag.String("chunker", ChunkLine, "chunking strategy: line, words, sentence, paragraph, recursive or speech")
+	chunkSize := flag.Int("chunk-size", 64, "words per chunk for the words and recursive chunkers")
+	chunkOverlap := flag.Int("chunk-overlap", 16, "words shared between neighbouring chunks for the words chunker")
+	workers := flag.Int("workers", 4, "number of embedding requests in flight at once")
+	batchTokens := flag.Int("batch-tokens", 8000, "estimated token budget for a single embedding request")
+	batchSize := flag.Int("batch-size", 256, "maximum number of texts in a single embedding request")
+	maxRetries := flag.Int("max-retries", 5, "retries for an embedding request after a rate limit or server error")
+	rps := flag.Float64("rps", 0, "maximum embedding requests started per second, 0 is unlimited")
+	baseURL := flag.String("base-url", "", "base URL of an OpenAI compatible API, e.g. http://localhost:8089/v1 for ../embedding-server")
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		log.Fatalf("unexpected arguments %q, pass the query with -query", flag.Args())
+	}
+	if *topK <= 0 {
+		log.Fatalf("-k must be positive, got %d", *topK)
+	}
+	if err := validateFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	if *indexKind != IndexLinear && *indexKind != IndexHNSW {
+		log.Fatalf("unknown index %q (want %s or %s)", *indexKind, IndexLinear, IndexHNSW)
+	}
+	metric, err := parseMetric(*hnswMetric)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hnswOpts := defaultHNSWOptions()
+	hnswOpts.M = *hnswM
+	hnswOpts.EfConstruction = *hnswEfConstruction
+	hnswOpts.EfSearch = *hnswEfSearch
+	hnswOpts.Metric = metric
+
 	ctx := context.Background()
-	fmt.Println(ctx)
 
-	// Read file into a byte slice
-	content, err := os.ReadFile("./corpora/shakespeare-macbeth.txt")
+	// Read every play in the corpus
+	docs, err := loadCorpus(*corpus)
 	if err != nil {
 		log.Fatalf("failed to read in documents: %v", err)
 	}
+	docs, err = selectDocuments(docs, *only)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Set up documents for embedding
-	documents := strings.Split(string(content), "\n")
-	var normDocuments []string
-	for _, d := range documents {
-		if d != "" {
-			normDocuments = append(normDocuments, d)
+	chunker, err := newChunker(*chunkStrategy, *chunkSize, *chunkOverlap)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passages := chunkCorpus(docs, chunker)
+
+	batchOpts := defaultBatchOptions()
+	batchOpts.Workers = *workers
+	batchOpts.MaxBatchTokens = *batchTokens
+	batchOpts.MaxBatchSize = *batchSize
+	batchOpts.MaxRetries = *maxRetries
+	batchOpts.RequestsPerSecond = *rps
+	batchOpts.Progress = func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\rprocessed %d/%d documents", done, total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
 		}
 	}
 
-	// documents := []string{
-	// 	"The cat is on the mat.",
-	// 	"There is a cat on the mat.",
-	// 	"The dog is in the yard.",
-	// 	"There is a dog in the yard.",
-	// }
-
-	// Set up query for embedding
-	// query := "When should we get together again?"
-	// Top Matches:
-	// 	1) score=0.9036 |   1. When shall we three meet againe?
-	// 	2) score=0.8353 | Our point of second meeting.
-	// 	3) score=0.8308 | When shalt thou see thy wholsome dayes againe?
-	// 	4) score=0.8297 | Was it not yesterday we spoke together?
-	// 	5) score=0.8135 | Shall we well meet them, that way are they comming
-	// query := "Will the ocean clean this blood?"
-	// Top Matches:
-	// 	1) score=0.9032 | Will all great Neptunes Ocean wash this blood
-	// 	2) score=0.8319 | What will these hands ne're be cleane? No more o'that
-	// 	3) score=0.8283 | A little Water cleares vs of this deed.
-	// 	4) score=0.8155 | Blood will haue Blood:
-	// 	5) score=0.8096 | Cleanse the stufft bosome, of that perillous stuffe
-	query := "I thought I heard someone yell, 'No more sleep'"
-	// Top Matches:
-	// 	1) score=0.8607 |    Macb. Me thought I heard a voyce cry, Sleep no more:
-	// 	2) score=0.8349 | Shall sleepe no more: Macbeth shall sleepe no more
-	// 	3) score=0.8306 | And yet I would not sleepe:
-	// 	4) score=0.8285 | Sleepe shall neyther Night nor Day
-	// 	5) score=0.8259 | Hath rung Nights yawning Peale,
-	// query := "A cat is sitting on a mat."
-
-	embedder := getEmbedder()
-	similarities := embedDocsAndQuery(ctx, embedder, query, normDocuments)
-
-	matches := make([]Match, 0, len(similarities))
-	for i, score := range similarities {
-		matches = append(matches, Match{
-			Index: i,
-			Score: score,
-		})
-	}
-
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].Score > matches[j].Score
-	})
-
-	topK := 5
-	if len(matches) < topK {
-		topK = len(matches)
-	}
-
-	fmt.Println("\nTop Matches:")
-	for i := 0; i < topK; i++ {
-		m := matches[i]
-		fmt.Printf("%d) score=%.4f | %s\n", i+1, m.Score, normDocuments[m.Index])
+	// Cache misses go through the batcher so only new text costs a request.
+	var embedder Embedder = newBatchingEmbedder(getEmbedder(*backend, *model, *dim, *baseURL), batchOpts)
+	var cache *cachedEmbedder
+	if *cacheDir != "" {
+		cacheModel, cacheDim := embedderModel(*backend, *model, *dim, *baseURL)
+		cache, err = newCachedEmbedder(embedder, *cacheDir, cacheModel, cacheDim)
+		if err != nil {
+			log.Fatalf("failed to open embedding cache: %v", err)
+		}
+		if *cacheClear {
+			if err := cache.Clear(); err != nil {
+				log.Fatalf("failed to clear embedding cache: %v", err)
+			}
+		}
+		if *cacheInvalidate {
+			if err := cache.InvalidateModel(); err != nil {
+				log.Fatalf("failed to invalidate embedding cache: %v", err)
+			}
+		}
+		embedder = cache
 	}
-}
 
-func embedDocsAndQuery(ctx context.Context, embedder *embeddings.EmbedderImpl, query string, documents []string) []float64 {
-	// Embed those documents
-	documentEmbeddings, err := embedder.EmbedDocuments(ctx, documents)
-	if err != nil {
-		log.Fatalf("failed to embed documents: %v", err)
-	}
+	searcher := newSearcher(ctx, embedder, passages, *verbose)
 
-	fmt.Println("Document embeddings:")
-	for i, vec := range documentEmbeddings {
-		fmt.Printf("Doc %d: len=%d, first 5 dims=%v\n", i, len(vec), vec[:5])
+	if cache != nil {
+		stats := cache.Stats()
+		fmt.Fprintf(os.Stderr, "embedding cache: %d hits, %d misses, %d entries\n", stats.Hits, stats.Misses, stats.Entries)
 	}
 
-	// Embed a query
-	queryEmbedding, err := embedder.EmbedQuery(ctx, query)
-	if err != nil {
-		log.Fatalf("failed to embed query: %v", err)
+	if *indexKind == IndexHNSW {
+		start := time.Now()
+		searcher.index, err = buildHNSW(searcher.embeddings, hnswOpts)
+		if err != nil {
+			log.Fatalf("failed to build HNSW index: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "HNSW index over %d passages built in %s\n", searcher.index.Len(), time.Since(start).Round(time.Millisecond))
 	}
 
-	fmt.Printf("\nQuery embedding: len=%d, first 5 dims=%v\n", len(queryEmbedding), queryEmbedding[:5])
-
-	// Calculate and return
-	similarities := querySimilarities(queryEmbedding, documentEmbeddings)
-
-	return similarities
-}
-
-func querySimilarities(query []float32, documentEmbeddings [][]float32) []float64 {
-	var results []float64
-	for _, doc := range documentEmbeddings {
-		results = append(results, cosineSimilarity(widenFloats(doc), widenFloats(query)))
+	if *interactive {
+		repl(ctx, searcher, os.Stdin, os.Stdout, *topK, *format)
+		return
 	}
 
-	return results
+	matches, err := searcher.Search(ctx, *query, *topK, *verbose)
+	if err != nil {
+		log.Fatalf("failed to search: %v", err)
+	}
+	printMatches(os.Stdout, *format, *query, matches, passages)
 }
 
-func cosineSimilarity(a, b []float64) float64 {
-	if len(a) != len(b) {
-		log.Fatal("vectors must be same length")
-	}
-	var dot, normA, normB float64
-	for i := range a {
-		dot += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
+// newSearcher embeds every passage. Passages that could not be embedded are logged and left out of results.
+func newSearcher(ctx context.Context, embedder Embedder, passages []Passage, verbose bool) *Searcher {
+	// Embed those documents
+	documentEmbeddings, err := embedder.EmbedDocuments(ctx, passageTexts(passages))
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		// Search what did get embedded rather than throwing the whole run away.
+		log.Printf("warning: %v", batchErr)
+		for _, f := range batchErr.Failures {
+			log.Printf("warning: documents %d-%d were not embedded: %v", f.Start, f.End-1, f.Err)
+		}
+	} else if err != nil {
+		log.Fatalf("failed to embed documents: %v", err)
 	}
-	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
-}
 
-func getEmbedder() *embeddings.EmbedderImpl {
-	if os.Getenv("OPENAI_API_KEY") == "" {
-		log.Fatalf("OPEN_API_KEY environment variable not set")
+	if verbose {
+		fmt.Println("Document embeddings:")
+		for i, vec := range documentEmbeddings {
+			if vec == nil {
+				continue
+			}
+			fmt.Printf("Doc %d: len=%d, first 5 dims=%v\n", i, len(vec), vec[:min(5, len(vec))])
+		}
 	}
 
-	// openai.New automatically checks OPENAI_API_KEY env var
-	llm, err := openai.New(
-		openai.WithModel("text-embedding-3-large"),
-	)
-	if err != nil {
-		log.Fatalf("failed to create OpenAI client: %v", err)
+	return &Searcher{
+		embedder:   embedder,
+		passages:   passages,
+		embeddings: documentEmbeddings,
+		norms:      vectorNorms(documentEmbeddings),
 	}
+}
 
-	embedder, err := embeddings.NewEmbedder(llm)
+// Search embeds the query and returns the k passages most similar to it.
+func (s *Searcher) Search(ctx context.Context, query string, k int, verbose bool) ([]Match, error) {
+	// Embed a query
+	queryEmbedding, err := s.embedder.EmbedQuery(ctx, query)
 	if err != nil {
-		log.Fatalf("failed to create an OpenAI embedding model: %v", err)
+		return nil, fmt.Errorf("failed to embed query: %w", err)
 	}
 
-	return embedder
-}
+	if verbose {
+		fmt.Printf("\nQuery embedding: len=%d, first 5 dims=%v\n", len(queryEmbedding), queryEmbedding[:min(5, len(queryEmbedding))])
+	}
 
-func widenFloats(fs []float32) []float64 {
-	result := make([]float64, 0, len(fs))
-	for _, f := range fs {
-		result = append(result, float64(f))
+	if s.index != nil {
+		return s.index.Search(queryEmbedding, k)
 	}
 
-	return result
+	return topKCosine(queryEmbedding, s.embeddings, s.norms, k, 0), nil
 }
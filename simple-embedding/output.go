@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+const (
+	FormatText  = "text"
+	FormatTSV   = "tsv"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+// Result is one ranked match in a form scripts can consume without scraping the text output.
+type Result struct {
+	// Query is only set in JSONL output where every line has to stand on its own.
+	Query    string         `json:"query,omitempty"`
+	Rank     int            `json:"rank"`
+	Score    float64        `json:"score"`
+	Text     string         `json:"text"`
+	Source   string         `json:"source"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+type resultSet struct {
+	Query   string   `json:"query"`
+	Results []Result `json:"results"`
+}
+
+func validateFormat(format string) error {
+	switch format {
+	case FormatText, FormatTSV, FormatJSON, FormatJSONL:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (want %s, %s, %s or %s)", format, FormatText, FormatTSV, FormatJSON, FormatJSONL)
+	}
+}
+
+// newResults turns ranked matches into results, with the play, line, byte offsets and speech details as metadata.
+func newResults(matches []Match, passages []Passage) []Result {
+	results := make([]Result, 0, len(matches))
+	for i, m := range matches {
+		p := passages[m.Index]
+		metadata := map[string]any{
+			"title":      p.Doc.Title(),
+			"line":       p.Line(),
+			"byte_start": p.Start,
+			"byte_end":   p.End,
+		}
+		if speech, ok := p.Doc.Play.speechAt(p.Start, p.End); ok {
+			metadata["act"] = speech.Act
+			metadata["scene"] = speech.Scene
+			metadata["speaker"] = speech.speakerName()
+		}
+
+		results = append(results, Result{
+			Rank:     i + 1,
+			Score:    m.Score,
+			Text:     p.Text,
+			Source:   p.Doc.Path,
+			Metadata: metadata,
+		})
+	}
+
+	return results
+}
+
+// printMatches writes ranked matches for a query.
+// text is for people, tsv prints rank, score, source and text one match per line for cut, sort and friends,
+// json prints the query and its results as one document and jsonl prints one result per line.
+func printMatches(w io.Writer, format, query string, matches []Match, passages []Passage) {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(resultSet{Query: query, Results: newResults(matches, passages)}); err != nil {
+			log.Fatalf("failed to write results: %v", err)
+		}
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, r := range newResults(matches, passages) {
+			r.Query = query
+			if err := enc.Encode(r); err != nil {
+				log.Fatalf("failed to write results: %v", err)
+			}
+		}
+	case FormatTSV:
+		for i, m := range matches {
+			p := passages[m.Index]
+			fmt.Fprintf(w, "%d\t%.4f\t%s\t%d\t%s\n", i+1, m.Score, p.Doc.Title(), p.Line(), strings.ReplaceAll(displayText(p.Text), "\t", " "))
+		}
+	default:
+		fmt.Fprintf(w, "\nTop Matches for %q:\n", query)
+		for i, m := range matches {
+			p := passages[m.Index]
+			fmt.Fprintf(w, "%d) score=%.4f | %s\n   %s\n", i+1, m.Score, displayText(p.Text), p.Label())
+		}
+	}
+}
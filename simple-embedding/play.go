@@ -0,0 +1,253 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The corpora follow the First Folio layout:
+//	[The Tragedie of Macbeth by William Shakespeare 1603]     title
+//	Actus Primus. Scoena Prima.                               act and scene headings, "Scena Secunda." on its own starts a new scene
+//	Scaena Quarta.                                            the printers spell it Scena, Scoena or Scaena
+//	Thunder and Lightning. Enter three Witches.               stage directions start at the margin
+//	  1. When shall we three meet againe?                     an indented line opens a speech with the speaker's abbreviation
+//	In Thunder, Lightning, or in Raine?                       verse continues at the margin until the next speech or direction
+// parsePlay turns that layout into speeches so a match can be reported as "Macbeth, Act II Scene 2, Macbeth speaking".
+
+type Speech struct {
+	Act   int
+	Scene int
+	// Speaker is the abbreviation as printed ("Macb"), Character is the full name when it could be resolved ("Macbeth").
+	Speaker   string
+	Character string
+	// Text is the speech without the speaker prefix, exactly as it appears in the source.
+	Text string
+	// Start and End are byte offsets of Text in the source.
+	Start int
+	End   int
+	// StartLine and EndLine are 1-based and inclusive.
+	StartLine int
+	EndLine   int
+}
+
+type Play struct {
+	Title    string
+	Speeches []Speech
+}
+
+var (
+	titlePattern   = regexp.MustCompile(`^\[The Tragedie of (.+?) by `)
+	actPattern     = regexp.MustCompile(`^Actus (\w+)\.?`)
+	scenePattern   = regexp.MustCompile(`Sc(?:a|ae|o|oe)?ena (\w+)\.?$`)
+	speakerPattern = regexp.MustCompile(`^\s{2,}(\S+?)\.?(?:\s+|$)`)
+	// directionPattern matches lines at the margin that are stage business rather than verse.
+	directionPattern = regexp.MustCompile(`^(?:(?:[A-Z][\w&, ]*\.\s+)*(?:Enter|Exit|Exeunt|Manet|Manent)\b|(?:Alarum|Alarums|Flourish|Thunder|Knock|Knocke|Knocking|Sennet|Senit|Hoboyes|Trumpets|Retreat|Drum|Drumme|Dyes|Dies|Musicke|Lowd|Noise|Bell)\b)`)
+)
+
+// latinOrdinals covers the act and scene numbering used in the Folio headings.
+var latinOrdinals = map[string]int{
+	"primus": 1, "prima": 1,
+	"secundus": 2, "secunda": 2,
+	"tertius": 3, "tertia": 3,
+	"quartus": 4, "quarta": 4,
+	"quintus": 5, "quinta": 5,
+	"sextus": 6, "sexta": 6,
+	"septimus": 7, "septima": 7,
+	"octavus": 8, "octaua": 8, "octava": 8,
+	"nonus": 9, "nona": 9,
+	"decimus": 10, "decima": 10,
+}
+
+func parsePlay(source string) Play {
+	var play Play
+	act, scene := 0, 0
+	inDirection := false
+	var current *Speech
+	var names []string
+
+	finish := func() {
+		if current != nil && current.End > current.Start {
+			play.Speeches = append(play.Speeches, *current)
+		}
+		current = nil
+	}
+
+	offset := 0
+	for lineNo, line := range strings.SplitAfter(source, "\n") {
+		start := offset
+		offset += len(line)
+		line = strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			inDirection = false
+			continue
+		}
+
+		if m := titlePattern.FindStringSubmatch(trimmed); m != nil && play.Title == "" {
+			play.Title = m[1]
+			continue
+		}
+
+		actMatch := actPattern.FindStringSubmatch(trimmed)
+		sceneMatch := scenePattern.FindStringSubmatch(trimmed)
+		if actMatch != nil || (sceneMatch != nil && strings.HasPrefix(trimmed, "Sc")) {
+			finish()
+			if actMatch != nil {
+				act = latinOrdinals[strings.ToLower(actMatch[1])]
+				scene = 1
+			}
+			if sceneMatch != nil {
+				scene = latinOrdinals[strings.ToLower(sceneMatch[1])]
+			}
+			continue
+		}
+
+		if line == trimmed && (inDirection || directionPattern.MatchString(trimmed)) {
+			finish()
+			names = append(names, entranceNames(trimmed, inDirection)...)
+			inDirection = !strings.HasSuffix(trimmed, ".")
+			continue
+		}
+		inDirection = false
+
+		if m := speakerPattern.FindStringSubmatchIndex(line); m != nil {
+			finish()
+			current = &Speech{
+				Act:       act,
+				Scene:     scene,
+				Speaker:   line[m[2]:m[3]],
+				Start:     start + m[1],
+				End:       start + len(line),
+				StartLine: lineNo + 1,
+				EndLine:   lineNo + 1,
+			}
+			continue
+		}
+
+		if current != nil {
+			current.End = start + len(line)
+			current.EndLine = lineNo + 1
+		}
+	}
+	finish()
+
+	resolveCharacters(play.Speeches, names)
+	for i := range play.Speeches {
+		s := &play.Speeches[i]
+		s.Text = source[s.Start:s.End]
+	}
+
+	return play
+}
+
+// entranceNames collects the capitalised words naming who enters or stays on stage, they are the candidates
+// for speaker abbreviations. Sound cues like "Musicke, and a Song." name nobody. A continued direction is
+// the tail of a cast list so every word counts.
+func entranceNames(direction string, continued bool) []string {
+	if !continued {
+		i := strings.Index(direction, "Enter ")
+		if i < 0 {
+			i = strings.Index(direction, "Manet ")
+		}
+		if i < 0 {
+			i = strings.Index(direction, "Manent ")
+		}
+		if i < 0 {
+			return nil
+		}
+		direction = direction[i:]
+	}
+
+	var names []string
+	for _, word := range strings.FieldsFunc(direction, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '.' || r == '&'
+	}) {
+		if len(word) > 1 && word[0] >= 'A' && word[0] <= 'Z' {
+			names = append(names, word)
+		}
+	}
+
+	return names
+}
+
+// resolveCharacters expands abbreviations like "Macb" or "Cassi" to the most frequently entering name they prefix.
+// Numbered speakers ("1", the First Witch) and group lines ("All") have no match and are left as printed.
+func resolveCharacters(speeches []Speech, names []string) {
+	counts := make(map[string]int)
+	for _, name := range names {
+		counts[name]++
+	}
+
+	resolved := make(map[string]string)
+	for i := range speeches {
+		abbr := speeches[i].Speaker
+		if full, ok := resolved[abbr]; ok {
+			speeches[i].Character = full
+			continue
+		}
+
+		full, best := abbr, 0
+		for name, count := range counts {
+			if strings.HasPrefix(name, abbr) && (count > best || (count == best && name < full)) {
+				full, best = name, count
+			}
+		}
+		resolved[abbr] = full
+		speeches[i].Character = full
+	}
+}
+
+// speechAt returns the first speech overlapping the byte range [start, end), if any.
+// A chunk can begin on a speaker prefix ("Macb. Me thought...") which is outside the speech text itself.
+func (p Play) speechAt(start, end int) (Speech, bool) {
+	i := sort.Search(len(p.Speeches), func(i int) bool {
+		return p.Speeches[i].End > start
+	})
+	if i == len(p.Speeches) || p.Speeches[i].Start >= end {
+		return Speech{}, false
+	}
+
+	return p.Speeches[i], true
+}
+
+// speakerName is the resolved character, numbered speakers like the witches in Macbeth read as "Speaker 1".
+func (s Speech) speakerName() string {
+	if strings.Trim(s.Character, "0123456789") == "" {
+		return "Speaker " + s.Character
+	}
+
+	return s.Character
+}
+
+func romanNumeral(n int) string {
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+
+	var b strings.Builder
+	for _, numeral := range numerals {
+		for n >= numeral.value {
+			b.WriteString(numeral.symbol)
+			n -= numeral.value
+		}
+	}
+
+	return b.String()
+}
+
+// speechChunker makes every speech turn its own chunk.
+type speechChunker struct{}
+
+func (speechChunker) Chunk(source string) []Chunk {
+	var chunks []Chunk
+	for _, s := range parsePlay(source).Speeches {
+		chunks = appendChunk(chunks, source, s.Start, s.End)
+	}
+
+	return chunks
+}
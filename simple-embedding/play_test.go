@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readPlay(t *testing.T, name string) (string, Play) {
+	t.Helper()
+
+	b, err := os.ReadFile("corpora/shakespeare-" + name + ".txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b), parsePlay(string(b))
+}
+
+// TestParsePlayScenes checks the scenes the speeches fall into against the headings of the corpus files, which
+// spell a scene Scena, Scoena and Scaena and leave out the first scene of an act.
+func TestParsePlayScenes(t *testing.T) {
+	tests := []struct {
+		play       string
+		wantTitle  string
+		wantScenes string
+	}{
+		{"macbeth", "Macbeth", "I.1 I.2 I.3 I.4 I.5 I.6 I.7 II.1 II.2 II.3 II.4 III.1 III.2 III.3 III.4 III.5 III.6 IV.1 IV.2 IV.3 V.1 V.2 V.3 V.4 V.5 V.6 V.7"},
+		{"hamlet", "Hamlet", "I.1 I.2 I.3 II.1 II.2"},
+		{"caesar", "Julius Caesar", "I.1 II.1 III.1 IV.1 V.1"},
+	}
+	for _, tt := range tests {
+		_, play := readPlay(t, tt.play)
+		if play.Title != tt.wantTitle {
+			t.Errorf("%s: title %q, want %q", tt.play, play.Title, tt.wantTitle)
+		}
+
+		var scenes []string
+		for _, s := range play.Speeches {
+			scene := fmt.Sprintf("%s.%d", romanNumeral(s.Act), s.Scene)
+			if len(scenes) == 0 || scenes[len(scenes)-1] != scene {
+				scenes = append(scenes, scene)
+			}
+		}
+		if got := strings.Join(scenes, " "); got != tt.wantScenes {
+			t.Errorf("%s: scenes\n%s\nwant\n%s", tt.play, got, tt.wantScenes)
+		}
+	}
+}
+
+func TestParsePlaySpeeches(t *testing.T) {
+	source, play := readPlay(t, "macbeth")
+
+	tests := []struct {
+		text               string
+		act, scene         int
+		speaker, character string
+		speakerName        string
+	}{
+		{"When shall we three meet againe?", 1, 1, "1", "1", "Speaker 1"},
+		{"You know your owne degrees, sit downe:", 3, 4, "Macb", "Macbeth", "Macbeth"},
+		{"To morrow, and to morrow, and to morrow,", 5, 5, "Macb", "Macbeth", "Macbeth"},
+		{"Let euery Souldier hew him downe a Bough,", 5, 4, "Malc", "Malcolme", "Malcolme"},
+	}
+	for _, tt := range tests {
+		at := strings.Index(source, tt.text)
+		if at < 0 {
+			t.Fatalf("%q is not in the corpus", tt.text)
+		}
+		s, ok := play.speechAt(at, at+len(tt.text))
+		if !ok {
+			t.Errorf("%q: no speech", tt.text)
+			continue
+		}
+		if s.Act != tt.act || s.Scene != tt.scene || s.Speaker != tt.speaker || s.Character != tt.character {
+			t.Errorf("%q: Act %d Scene %d, %s (%s), want Act %d Scene %d, %s (%s)", tt.text, s.Act, s.Scene, s.Speaker, s.Character, tt.act, tt.scene, tt.speaker, tt.character)
+		}
+		if s.Text != source[s.Start:s.End] || !strings.Contains(s.Text, tt.text) {
+			t.Errorf("%q: speech text %q does not hold it", tt.text, s.Text)
+		}
+		if s.speakerName() != tt.speakerName {
+			t.Errorf("%q: speaker name %q, want %q", tt.text, s.speakerName(), tt.speakerName)
+		}
+	}
+}
+
+func TestPassageLabel(t *testing.T) {
+	source := "[The Tragedie of Macbeth by William Shakespeare 1603]\n\n" +
+		"Enter Macbeth.\n\n  Macb. So foule and faire a day I haue not seene.\n\n" +
+		"Actus Tertius. Scena Prima.\n\nEnter Banquo.\n\n  Banq. Thou hast it now\n\n" +
+		"Scaena Quarta.\n\n  Macb. You know your owne degrees\n\n" +
+		"Exeunt.\n\nA line nobody speaks.\n"
+	doc := newDocument("corpora/macbeth.txt", source)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"So foule", "Macbeth, line 5, Macbeth speaking"},
+		{"Thou hast", "Macbeth, line 11, Act III Scene 1, Banquo speaking"},
+		{"You know", "Macbeth, line 15, Act III Scene 4, Macbeth speaking"},
+		{"A line nobody", "Macbeth, line 19"},
+	}
+	for _, tt := range tests {
+		at := strings.Index(source, tt.text)
+		p := Passage{Chunk: Chunk{Text: tt.text, Start: at, End: at + len(tt.text)}, Doc: doc}
+		if got := p.Label(); got != tt.want {
+			t.Errorf("Label of %q = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestSpeechChunks checks the speech chunker against the same offsets as the other strategies.
+func TestSpeechChunks(t *testing.T) {
+	for name, source := range readCorpora(t) {
+		chunks := speechChunker{}.Chunk(source)
+		if len(chunks) < 500 {
+			t.Errorf("%s: only %d speeches", name, len(chunks))
+		}
+		for i, c := range chunks {
+			if c.Text != source[c.Start:c.End] || (i > 0 && c.Start < chunks[i-1].End) {
+				t.Fatalf("%s: speech %d [%d, %d) is not its source text or overlaps the last", name, i, c.Start, c.End)
+			}
+		}
+	}
+}
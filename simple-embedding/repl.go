@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const replHelp = `Type a query and press enter to search.
+  :k N      show N matches per query
+  :help     show this message
+  :quit     exit (so does Ctrl-D)`
+
+// repl answers queries from in until EOF or :quit, reusing the corpus embeddings for every query.
+// With json or jsonl output the prompt and messages go to stderr so stdout stays machine readable.
+func repl(ctx context.Context, searcher *Searcher, in io.Reader, out io.Writer, topK int, format string) {
+	ui := out
+	if format == FormatJSON || format == FormatJSONL {
+		ui = os.Stderr
+	}
+
+	fmt.Fprintf(ui, "%d passages embedded. %s\n", len(searcher.passages), replHelp)
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(ui, "\nquery> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(ui)
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == ":quit" || line == ":q" || line == "exit":
+			return
+		case line == ":help":
+			fmt.Fprintln(ui, replHelp)
+			continue
+		case strings.HasPrefix(line, ":k"):
+			k, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, ":k")))
+			if err != nil || k <= 0 {
+				fmt.Fprintln(ui, "usage: :k N with N > 0")
+				continue
+			}
+			topK = k
+			fmt.Fprintf(ui, "showing %d matches\n", topK)
+			continue
+		case strings.HasPrefix(line, ":"):
+			fmt.Fprintf(ui, "unknown command %q, try :help\n", line)
+			continue
+		}
+
+		matches, err := searcher.Search(ctx, line, topK, false)
+		if err != nil {
+			fmt.Fprintf(ui, "error: %v\n", err)
+			continue
+		}
+		printMatches(out, format, line, matches, searcher.passages)
+	}
+}
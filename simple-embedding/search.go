@@ -0,0 +1,137 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Scoring works on the float32 vectors the embedder returns, with document norms computed once up front,
+// so a query costs one dot product per document and no allocations. The corpus is split into one shard
+// per core and each shard keeps only its best k matches in a min-heap, the shards are merged at the end.
+
+// vectorNorms returns the L2 norm of every vector, nil vectors (never embedded) get 0.
+func vectorNorms(vectors [][]float32) []float32 {
+	norms := make([]float32, len(vectors))
+	for i, v := range vectors {
+		norms[i] = norm32(v)
+	}
+
+	return norms
+}
+
+func norm32(v []float32) float32 {
+	var sum float32
+	for _, x := range v {
+		sum += x * x
+	}
+
+	return float32(math.Sqrt(float64(sum)))
+}
+
+func dot32(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+
+	return sum
+}
+
+// matchHeap is a min-heap on score so the weakest of the best k is always at the root.
+type matchHeap []Match
+
+func (h matchHeap) Len() int { return len(h) }
+func (h matchHeap) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score < h[j].Score
+	}
+	// Among equal scores the later document is weaker so ties rank in corpus order.
+	return h[i].Index > h[j].Index
+}
+func (h matchHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *matchHeap) Push(x any)   { *h = append(*h, x.(Match)) }
+func (h *matchHeap) Pop() any {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
+
+// offer adds m if the heap has room or m beats the weakest match kept so far.
+func (h *matchHeap) offer(m Match, k int) {
+	if h.Len() < k {
+		heap.Push(h, m)
+		return
+	}
+	if (*h)[0].Score < m.Score || ((*h)[0].Score == m.Score && (*h)[0].Index > m.Index) {
+		(*h)[0] = m
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted returns the kept matches best first.
+func (h matchHeap) sorted() []Match {
+	matches := append([]Match(nil), h...)
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Index < matches[j].Index
+	})
+
+	return matches
+}
+
+// scoreRange keeps the best k cosine matches for documents [start, end).
+func scoreRange(query []float32, queryNorm float32, docs [][]float32, norms []float32, start, end, k int) matchHeap {
+	h := make(matchHeap, 0, k)
+	for i := start; i < end; i++ {
+		// A vector of another length came from another model, it can't be compared with the query.
+		if docs[i] == nil || norms[i] == 0 || len(docs[i]) != len(query) {
+			continue
+		}
+		score := dot32(query, docs[i]) / (queryNorm * norms[i])
+		h.offer(Match{Index: i, Score: float64(score)}, k)
+	}
+
+	return h
+}
+
+// topKCosine returns the k documents most similar to query, best first, scoring shards of the corpus in parallel.
+func topKCosine(query []float32, docs [][]float32, norms []float32, k, workers int) []Match {
+	queryNorm := norm32(query)
+	if queryNorm == 0 || k <= 0 {
+		return nil
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	// Tiny shards cost more in goroutines than they save.
+	workers = max(1, min(workers, len(docs)/1024))
+
+	shard := (len(docs) + workers - 1) / workers
+	heaps := make([]matchHeap, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		start := w * shard
+		end := min(start+shard, len(docs))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			heaps[w] = scoreRange(query, queryNorm, docs, norms, start, end, k)
+		}()
+	}
+	wg.Wait()
+
+	merged := make(matchHeap, 0, k)
+	for _, h := range heaps {
+		for _, m := range h {
+			merged.offer(m, k)
+		}
+	}
+
+	return merged.sorted()
+}
@@ -0,0 +1,55 @@
+package text
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LoadCorpus reads every file matching a glob in name order, each file is one document.
+func LoadCorpus(pattern string) (paths, docs []string, err error) {
+	paths, err = filepath.Glob(pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad corpus pattern %q: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no corpus files found at %q", pattern)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		docs = append(docs, string(content))
+	}
+
+	return paths, docs, nil
+}
+
+// TokenizeAll returns the tokens of every document in a corpus.
+func TokenizeAll(docs []string, tokenizer Tokenizer) [][]string {
+	tokens := make([][]string, len(docs))
+	for i, doc := range docs {
+		tokens[i] = tokenizer.Tokenize(doc)
+	}
+
+	return tokens
+}
+
+// BuildVocab maps every term to its index in the vocabulary, numbered in the order the documents first use them.
+// The terms can be words or n-gram features like "process of", whatever the documents were turned into.
+func BuildVocab(docs [][]string) map[string]int {
+	vocab := make(map[string]int)
+	for _, doc := range docs {
+		for _, term := range doc {
+			if _, exists := vocab[term]; !exists {
+				vocab[term] = len(vocab)
+			}
+		}
+	}
+
+	return vocab
+}
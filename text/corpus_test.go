@@ -0,0 +1,50 @@
+package text
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadCorpus(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"b.txt": "second", "a.txt": "first", "c.md": "skipped"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, docs, err := LoadCorpus(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPaths := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	if !slices.Equal(paths, wantPaths) || !slices.Equal(docs, []string{"first", "second"}) {
+		t.Errorf("got %q and %q, want %q and the files in name order", paths, docs, wantPaths)
+	}
+
+	for _, pattern := range []string{filepath.Join(dir, "*.csv"), filepath.Join(dir, "[")} {
+		if _, _, err := LoadCorpus(pattern); err == nil {
+			t.Errorf("LoadCorpus(%q) succeeded", pattern)
+		}
+	}
+}
+
+func TestTokenizeAll(t *testing.T) {
+	docs := []string{"The dog.", "", "A dog's life"}
+	got := TokenizeAll(docs, NewWordTokenizer(FoldCase))
+	want := [][]string{{"the", "dog"}, {}, {"a", "dog's", "life"}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("TokenizeAll(%q) = %q, want %q", docs, got, want)
+	}
+}
+
+func TestBuildVocab(t *testing.T) {
+	got := BuildVocab([][]string{{"my", "dog", "my"}, nil, {"dog", "is", "process of"}})
+	want := map[string]int{"my": 0, "dog": 1, "is": 2, "process of": 3}
+	if !maps.Equal(got, want) {
+		t.Errorf("BuildVocab = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/quinn-collins/text
+
+go 1.25.6
@@ -0,0 +1,106 @@
+package text
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A vocabulary of single words throws word order away: "dog bites man" and "man bites dog" are the same bag.
+// N-grams put some of it back by making runs of neighbouring words features of their own, so with bigrams the
+// vocabulary holds "dog bites" and "bites man" next to "dog", "bites" and "man".
+// Character n-grams do the same inside words: "tokenizing" and "tokenization" share "<to", "tok", "oke" and
+// more, whereas as whole words they share nothing.
+// Features works on the tokens it is given. From a Tokenizer those have already lost their stop words, so with
+// the english list the bigrams of "the process of tokenizing" are "process tokenizing", not "process of".
+
+// NGramOptions picks the features a document's tokens become.
+type NGramOptions struct {
+	// MinN and MaxN bound the word n-grams, 1 and 1 are the plain words.
+	MinN, MaxN int
+	// CharMinN and CharMaxN bound the character n-grams of every word. Set, they replace the word n-grams.
+	CharMinN, CharMaxN int
+}
+
+// ParseNGramRange reads a range like "2" or "1-3".
+func ParseNGramRange(s string) (minN, maxN int, err error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	if minN, err = strconv.Atoi(lo); err != nil {
+		return 0, 0, fmt.Errorf("bad n-gram range %q: want n or min-max", s)
+	}
+	maxN = minN
+	if isRange {
+		if maxN, err = strconv.Atoi(hi); err != nil {
+			return 0, 0, fmt.Errorf("bad n-gram range %q: want n or min-max", s)
+		}
+	}
+	if minN < 1 || maxN < minN {
+		return 0, 0, fmt.Errorf("bad n-gram range %q: want 1 <= min <= max", s)
+	}
+
+	return minN, maxN, nil
+}
+
+// NewNGramOptions builds the options from the -ngrams and -char-ngrams flags, the second empty for word n-grams.
+func NewNGramOptions(words, chars string) (NGramOptions, error) {
+	var opts NGramOptions
+	var err error
+	if opts.MinN, opts.MaxN, err = ParseNGramRange(words); err != nil {
+		return NGramOptions{}, err
+	}
+	if chars != "" {
+		if opts.CharMinN, opts.CharMaxN, err = ParseNGramRange(chars); err != nil {
+			return NGramOptions{}, err
+		}
+	}
+
+	return opts, nil
+}
+
+// Unigrams reports whether the options leave the tokens as they are.
+func (o NGramOptions) Unigrams() bool {
+	return o.CharMaxN == 0 && o.MinN == 1 && o.MaxN == 1
+}
+
+// Features turns the tokens of every document into the features the vocabulary is built from.
+func Features(tokens [][]string, opts NGramOptions) [][]string {
+	result := make([][]string, len(tokens))
+	for i, doc := range tokens {
+		if opts.CharMaxN > 0 {
+			for _, word := range doc {
+				result[i] = append(result[i], CharNGrams(word, opts.CharMinN, opts.CharMaxN)...)
+			}
+			continue
+		}
+		result[i] = WordNGrams(doc, opts.MinN, opts.MaxN)
+	}
+
+	return result
+}
+
+// WordNGrams returns every run of minN to maxN neighbouring tokens joined by spaces, shortest first, so the
+// tokens of "the process of" give "the", "process", "of", "the process" and "process of" for 1-2.
+func WordNGrams(tokens []string, minN, maxN int) []string {
+	var grams []string
+	for n := minN; n <= maxN; n++ {
+		for i := 0; i+n <= len(tokens); i++ {
+			grams = append(grams, strings.Join(tokens[i:i+n], " "))
+		}
+	}
+
+	return grams
+}
+
+// CharNGrams returns the minN to maxN letter runs of a word marked with < at its start and > at its end, so
+// the trigrams of "of" are "<of" and "of>" and a prefix never matches the same letters in the middle of a word.
+func CharNGrams(word string, minN, maxN int) []string {
+	runes := []rune("<" + word + ">")
+	var grams []string
+	for n := minN; n <= maxN; n++ {
+		for i := 0; i+n <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+n]))
+		}
+	}
+
+	return grams
+}
@@ -0,0 +1,78 @@
+package text
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseNGramRange(t *testing.T) {
+	tests := []struct {
+		s          string
+		minN, maxN int
+		wantErr    bool
+	}{
+		{"1", 1, 1, false},
+		{"2", 2, 2, false},
+		{"1-3", 1, 3, false},
+		{"3-5", 3, 5, false},
+		{"", 0, 0, true},
+		{"0", 0, 0, true},
+		{"3-2", 0, 0, true},
+		{"1-", 0, 0, true},
+		{"a-b", 0, 0, true},
+		{"-2", 0, 0, true},
+	}
+	for _, tt := range tests {
+		minN, maxN, err := ParseNGramRange(tt.s)
+		if (err != nil) != tt.wantErr || minN != tt.minN || maxN != tt.maxN {
+			t.Errorf("ParseNGramRange(%q) = %d, %d, %v, want %d, %d, error %t", tt.s, minN, maxN, err, tt.minN, tt.maxN, tt.wantErr)
+		}
+	}
+}
+
+func TestCharNGrams(t *testing.T) {
+	tests := []struct {
+		word       string
+		minN, maxN int
+		want       []string
+	}{
+		{"of", 3, 3, []string{"<of", "of>"}},
+		{"of", 1, 2, []string{"<", "o", "f", ">", "<o", "of", "f>"}},
+		{"a", 4, 5, nil},
+		{"dog", 3, 4, []string{"<do", "dog", "og>", "<dog", "dog>"}},
+		{"οδός", 3, 3, []string{"<οδ", "οδό", "δός", "ός>"}},
+	}
+	for _, tt := range tests {
+		if got := CharNGrams(tt.word, tt.minN, tt.maxN); !slices.Equal(got, tt.want) {
+			t.Errorf("CharNGrams(%q, %d, %d) = %q, want %q", tt.word, tt.minN, tt.maxN, got, tt.want)
+		}
+	}
+}
+
+func TestWordNGrams(t *testing.T) {
+	tokens := []string{"the", "process", "of"}
+	want := []string{"the", "process", "of", "the process", "process of"}
+	if got := WordNGrams(tokens, 1, 2); !slices.Equal(got, want) {
+		t.Errorf("WordNGrams(%q, 1, 2) = %q, want %q", tokens, got, want)
+	}
+	if got := WordNGrams(tokens, 4, 4); got != nil {
+		t.Errorf("WordNGrams(%q, 4, 4) = %q, want none", tokens, got)
+	}
+}
+
+// TestFeaturesAfterStopWords shows that n-grams join the words the stop word list leaves.
+func TestFeaturesAfterStopWords(t *testing.T) {
+	tokenizer, err := NewTokenizer(TokenizerOptions{StopWords: "english"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := NewNGramOptions("2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := Features([][]string{tokenizer.Tokenize("The process of tokenizing")}, opts)
+	if want := []string{"process tokenizing"}; !slices.Equal(got[0], want) {
+		t.Errorf("bigrams = %q, want %q", got[0], want)
+	}
+}
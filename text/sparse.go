@@ -0,0 +1,143 @@
+package text
+
+import (
+	"math"
+	"sort"
+)
+
+// Almost every entry of a bag of words is 0: a document uses a few hundred of the thousands of words in the
+// vocabulary. SparseVector only keeps the others, so its size follows the document rather than the vocabulary,
+// and dot products only visit the entries both vectors have.
+
+// SparseVector is a vector of length Dim whose non-zero values are Values[k] at Indices[k], indices ascending.
+type SparseVector struct {
+	Dim     int
+	Indices []int
+	Values  []float64
+}
+
+// NewSparseVector builds a vector of length dim from its non-zero entries, zeros are left out.
+func NewSparseVector(dim int, entries map[int]float64) SparseVector {
+	v := SparseVector{Dim: dim}
+	for i, val := range entries {
+		if val != 0 {
+			v.Indices = append(v.Indices, i)
+		}
+	}
+	sort.Ints(v.Indices)
+	v.Values = make([]float64, len(v.Indices))
+	for k, i := range v.Indices {
+		v.Values[k] = entries[i]
+	}
+
+	return v
+}
+
+// SparseCounts counts how many times each vocabulary entry appears in a document, the bag of words of the
+// document without a one-hot vector for every token.
+func SparseCounts(doc []string, vocab map[string]int) SparseVector {
+	counts := make(map[int]float64)
+	for _, word := range doc {
+		if i, ok := vocab[word]; ok {
+			counts[i]++
+		}
+	}
+
+	return NewSparseVector(len(vocab), counts)
+}
+
+// At returns the value at index i.
+func (v SparseVector) At(i int) float64 {
+	if k := sort.SearchInts(v.Indices, i); k < len(v.Indices) && v.Indices[k] == i {
+		return v.Values[k]
+	}
+
+	return 0
+}
+
+// Dot walks both index lists together and multiplies the entries they share.
+func (v SparseVector) Dot(w SparseVector) float64 {
+	assertSparseDimsEqual(v, w)
+
+	var dot float64
+	for j, k := 0, 0; j < len(v.Indices) && k < len(w.Indices); {
+		switch {
+		case v.Indices[j] < w.Indices[k]:
+			j++
+		case v.Indices[j] > w.Indices[k]:
+			k++
+		default:
+			dot += v.Values[j] * w.Values[k]
+			j++
+			k++
+		}
+	}
+
+	return dot
+}
+
+// Norm is the Euclidean length of v.
+func (v SparseVector) Norm() float64 {
+	var sum float64
+	for _, val := range v.Values {
+		sum += val * val
+	}
+
+	return math.Sqrt(sum)
+}
+
+// Cosine is the cosine similarity of v and w, 0 when either is all zeros.
+func (v SparseVector) Cosine(w SparseVector) float64 {
+	normV, normW := v.Norm(), w.Norm()
+	if normV == 0 || normW == 0 {
+		return 0
+	}
+
+	return v.Dot(w) / (normV * normW)
+}
+
+// Add returns v + w, merging the index lists the way Dot walks them.
+func (v SparseVector) Add(w SparseVector) SparseVector {
+	assertSparseDimsEqual(v, w)
+
+	sum := SparseVector{Dim: v.Dim}
+	appendEntry := func(i int, val float64) {
+		if val != 0 {
+			sum.Indices = append(sum.Indices, i)
+			sum.Values = append(sum.Values, val)
+		}
+	}
+	j, k := 0, 0
+	for j < len(v.Indices) || k < len(w.Indices) {
+		switch {
+		case k == len(w.Indices) || (j < len(v.Indices) && v.Indices[j] < w.Indices[k]):
+			appendEntry(v.Indices[j], v.Values[j])
+			j++
+		case j == len(v.Indices) || v.Indices[j] > w.Indices[k]:
+			appendEntry(w.Indices[k], w.Values[k])
+			k++
+		default:
+			appendEntry(v.Indices[j], v.Values[j]+w.Values[k])
+			j++
+			k++
+		}
+	}
+
+	return sum
+}
+
+// Dense returns v with its zeros written out, for printing small vectors.
+func (v SparseVector) Dense() []float64 {
+	dense := make([]float64, v.Dim)
+	for k, i := range v.Indices {
+		dense[i] = v.Values[k]
+	}
+
+	return dense
+}
+
+func assertSparseDimsEqual(v, w SparseVector) {
+	if v.Dim != w.Dim {
+		panic("vectors must have the same length")
+	}
+}
@@ -0,0 +1,95 @@
+package text
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// randomSparse returns a vector of length dim with about a quarter of its entries set, and the same vector dense.
+func randomSparse(r *rand.Rand, dim int) (SparseVector, []float64) {
+	dense := make([]float64, dim)
+	entries := make(map[int]float64)
+	for i := range dense {
+		if r.IntN(4) == 0 {
+			dense[i] = float64(r.IntN(7) - 3)
+			entries[i] = dense[i]
+		}
+	}
+
+	return NewSparseVector(dim, entries), dense
+}
+
+func denseDot(a, b []float64) float64 {
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+
+	return dot
+}
+
+func denseCosine(a, b []float64) float64 {
+	normA, normB := math.Sqrt(denseDot(a, a)), math.Sqrt(denseDot(b, b))
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return denseDot(a, b) / (normA * normB)
+}
+
+// TestSparseMatchesDense checks Dot, Cosine, Add and At against the same sums over the written out vectors.
+func TestSparseMatchesDense(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, dim := range []int{0, 1, 5, 40, 300} {
+		for range 20 {
+			v, a := randomSparse(r, dim)
+			w, b := randomSparse(r, dim)
+
+			if got, want := v.Dot(w), denseDot(a, b); got != want {
+				t.Errorf("dim %d: Dot = %v, want %v", dim, got, want)
+			}
+			if got, want := v.Cosine(w), denseCosine(a, b); math.Abs(got-want) > 1e-12 {
+				t.Errorf("dim %d: Cosine = %v, want %v", dim, got, want)
+			}
+
+			sum := make([]float64, dim)
+			for i := range sum {
+				sum[i] = a[i] + b[i]
+			}
+			got := v.Add(w)
+			if !slices.Equal(got.Dense(), sum) {
+				t.Errorf("dim %d: Add = %v, want %v", dim, got.Dense(), sum)
+			}
+			if slices.Contains(got.Values, 0) || !slices.IsSorted(got.Indices) {
+				t.Errorf("dim %d: Add kept a zero or lost the index order: %+v", dim, got)
+			}
+			for i := range dim {
+				if v.At(i) != a[i] {
+					t.Errorf("dim %d: At(%d) = %v, want %v", dim, i, v.At(i), a[i])
+				}
+			}
+		}
+	}
+}
+
+func TestSparseCounts(t *testing.T) {
+	vocab := map[string]int{"dog": 0, "bites": 1, "man": 2, "cat": 3}
+	got := SparseCounts([]string{"dog", "bites", "man", "dog", "unknown"}, vocab)
+	if want := []float64{2, 1, 1, 0}; !slices.Equal(got.Dense(), want) {
+		t.Errorf("SparseCounts = %v, want %v", got.Dense(), want)
+	}
+	if !slices.Equal(got.Indices, []int{0, 1, 2}) {
+		t.Errorf("SparseCounts kept indices %v, want [0 1 2]", got.Indices)
+	}
+}
+
+func TestSparseDimMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Dot of vectors of different lengths did not panic")
+		}
+	}()
+	NewSparseVector(2, nil).Dot(NewSparseVector(3, nil))
+}
@@ -0,0 +1,292 @@
+package text
+
+import "strings"
+
+// Stem reduces a lower case English word to its stem with the Porter2 (Snowball English) stemmer, so
+// "tokenization", "tokenizing" and "tokenized" all become "token". Stems are not always words ("happy" becomes
+// "happi"), they only have to be the same for words that share a root.
+// These are the original Porter2 rules from https://snowballstem.org/algorithms/english/stemmer.html, without
+// the few refinements Snowball has added since.
+func Stem(word string) string {
+	if s, ok := stemExceptions[word]; ok {
+		return s
+	}
+	word = strings.ReplaceAll(word, "’", "'")
+	if len(word) < 3 {
+		return word
+	}
+
+	w := porter2Prelude(word)
+	r1, r2 := porter2Regions(w)
+	s := &stemmer{w: w, r1: r1, r2: r2}
+
+	s.step0()
+	s.step1a()
+	if stemInvariants[s.w] {
+		return s.w
+	}
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+
+	return strings.ReplaceAll(s.w, "Y", "y")
+}
+
+// stemExceptions are irregular words the rules would get wrong, checked before anything else.
+var stemExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// stemInvariants are left alone once step 1a has removed plurals.
+var stemInvariants = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true, "earring": true,
+	"proceed": true, "exceed": true, "succeed": true,
+}
+
+// porter2Prelude drops a leading apostrophe and marks a y that acts as a consonant as Y.
+func porter2Prelude(word string) string {
+	b := []byte(strings.TrimPrefix(word, "'"))
+	for i := range b {
+		if b[i] == 'y' && (i == 0 || isStemVowel(b[i-1])) {
+			b[i] = 'Y'
+		}
+	}
+
+	return string(b)
+}
+
+// porter2Regions finds R1, the part after the first non-vowel that follows a vowel, and R2, the same again
+// inside R1. Suffixes are only removed when they lie in the region a rule asks for.
+func porter2Regions(w string) (r1, r2 int) {
+	r1 = -1
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(w, prefix) {
+			r1 = len(prefix)
+			break
+		}
+	}
+	if r1 < 0 {
+		r1 = regionAfter(w, 0)
+	}
+
+	return r1, regionAfter(w, r1)
+}
+
+func regionAfter(w string, start int) int {
+	for i := start + 1; i < len(w); i++ {
+		if isStemVowel(w[i-1]) && !isStemVowel(w[i]) {
+			return i + 1
+		}
+	}
+
+	return len(w)
+}
+
+func isStemVowel(c byte) bool {
+	return strings.IndexByte("aeiouy", c) >= 0
+}
+
+func containsStemVowel(s string) bool {
+	return strings.IndexAny(s, "aeiouy") >= 0
+}
+
+// endsShortSyllable reports whether w ends in a vowel between two non-vowels, the last not w, x or Y, or is
+// just a vowel followed by a non-vowel.
+func endsShortSyllable(w string) bool {
+	n := len(w)
+	switch {
+	case n == 2:
+		return isStemVowel(w[0]) && !isStemVowel(w[1])
+	case n > 2:
+		return !isStemVowel(w[n-3]) && isStemVowel(w[n-2]) && !isStemVowel(w[n-1]) && strings.IndexByte("wxY", w[n-1]) < 0
+	default:
+		return false
+	}
+}
+
+type stemmer struct {
+	w      string
+	r1, r2 int
+}
+
+type stemRule struct {
+	suffix, replacement string
+}
+
+// longest returns the longest of rules whose suffix ends the word. The rule that matches is the only one
+// tried, if its condition fails the word is left as it is.
+func (s *stemmer) longest(rules []stemRule) (stemRule, bool) {
+	var best stemRule
+	found := false
+	for _, r := range rules {
+		if strings.HasSuffix(s.w, r.suffix) && (!found || len(r.suffix) > len(best.suffix)) {
+			best, found = r, true
+		}
+	}
+
+	return best, found
+}
+
+func (s *stemmer) inR1(suffix string) bool { return len(s.w)-len(suffix) >= s.r1 }
+func (s *stemmer) inR2(suffix string) bool { return len(s.w)-len(suffix) >= s.r2 }
+
+func (s *stemmer) replace(suffix, replacement string) {
+	s.w = s.w[:len(s.w)-len(suffix)] + replacement
+}
+
+// step0 removes possessives.
+func (s *stemmer) step0() {
+	if r, ok := s.longest([]stemRule{{"'s'", ""}, {"'s", ""}, {"'", ""}}); ok {
+		s.replace(r.suffix, "")
+	}
+}
+
+// step1a removes plurals.
+func (s *stemmer) step1a() {
+	r, ok := s.longest([]stemRule{{"sses", "ss"}, {"ied", ""}, {"ies", ""}, {"us", ""}, {"ss", ""}, {"s", ""}})
+	if !ok {
+		return
+	}
+
+	switch r.suffix {
+	case "sses":
+		s.replace(r.suffix, r.replacement)
+	case "ied", "ies":
+		// "cries" becomes "cri" but "ties" becomes "tie".
+		if len(s.w) > 4 {
+			s.replace(r.suffix, "i")
+		} else {
+			s.replace(r.suffix, "ie")
+		}
+	case "s":
+		// Only when a vowel comes before the letter ahead of the s, so "gaps" loses it but "gas" doesn't.
+		if containsStemVowel(s.w[:len(s.w)-2]) {
+			s.replace(r.suffix, "")
+		}
+	}
+}
+
+// step1b removes -ed and -ing and repairs what they leave behind, "hopping" becomes "hop" and "hoping" "hope".
+func (s *stemmer) step1b() {
+	r, ok := s.longest([]stemRule{{"eed", ""}, {"eedly", ""}, {"ed", ""}, {"edly", ""}, {"ing", ""}, {"ingly", ""}})
+	if !ok {
+		return
+	}
+
+	switch r.suffix {
+	case "eed", "eedly":
+		if s.inR1(r.suffix) {
+			s.replace(r.suffix, "ee")
+		}
+	default:
+		if !containsStemVowel(s.w[:len(s.w)-len(r.suffix)]) {
+			return
+		}
+		s.replace(r.suffix, "")
+
+		n := len(s.w)
+		switch {
+		case strings.HasSuffix(s.w, "at") || strings.HasSuffix(s.w, "bl") || strings.HasSuffix(s.w, "iz"):
+			s.w += "e"
+		case n >= 2 && s.w[n-1] == s.w[n-2] && strings.IndexByte("bdfgmnprt", s.w[n-1]) >= 0:
+			s.w = s.w[:n-1]
+		case s.r1 >= n && endsShortSyllable(s.w):
+			s.w += "e"
+		}
+	}
+}
+
+// step1c turns a final y after a consonant into i, "cry" becomes "cri" but "by" and "say" stay.
+func (s *stemmer) step1c() {
+	n := len(s.w)
+	if n > 2 && (s.w[n-1] == 'y' || s.w[n-1] == 'Y') && !isStemVowel(s.w[n-2]) {
+		s.w = s.w[:n-1] + "i"
+	}
+}
+
+var step2Rules = []stemRule{
+	{"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"abli", "able"}, {"entli", "ent"},
+	{"izer", "ize"}, {"ization", "ize"}, {"ational", "ate"}, {"ation", "ate"}, {"ator", "ate"},
+	{"alism", "al"}, {"aliti", "al"}, {"alli", "al"}, {"fulness", "ful"}, {"ousli", "ous"}, {"ousness", "ous"},
+	{"iveness", "ive"}, {"iviti", "ive"}, {"biliti", "ble"}, {"bli", "ble"}, {"ogi", "og"},
+	{"fulli", "ful"}, {"lessli", "less"}, {"li", ""},
+}
+
+// step2 maps derivational suffixes in R1 onto shorter ones.
+func (s *stemmer) step2() {
+	r, ok := s.longest(step2Rules)
+	if !ok || !s.inR1(r.suffix) {
+		return
+	}
+
+	before := len(s.w) - len(r.suffix) - 1
+	switch r.suffix {
+	case "ogi":
+		if before < 0 || s.w[before] != 'l' {
+			return
+		}
+	case "li":
+		if before < 0 || strings.IndexByte("cdeghkmnrt", s.w[before]) < 0 {
+			return
+		}
+	}
+	s.replace(r.suffix, r.replacement)
+}
+
+var step3Rules = []stemRule{
+	{"tional", "tion"}, {"ational", "ate"}, {"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"}, {"ical", "ic"},
+	{"ful", ""}, {"ness", ""}, {"ative", ""},
+}
+
+// step3 removes or shortens the suffixes step 2 leaves behind.
+func (s *stemmer) step3() {
+	r, ok := s.longest(step3Rules)
+	if !ok || !s.inR1(r.suffix) {
+		return
+	}
+	if r.suffix == "ative" && !s.inR2(r.suffix) {
+		return
+	}
+	s.replace(r.suffix, r.replacement)
+}
+
+var step4Rules = []stemRule{
+	{"al", ""}, {"ance", ""}, {"ence", ""}, {"er", ""}, {"ic", ""}, {"able", ""}, {"ible", ""}, {"ant", ""},
+	{"ement", ""}, {"ment", ""}, {"ent", ""}, {"ism", ""}, {"ate", ""}, {"iti", ""}, {"ous", ""}, {"ive", ""},
+	{"ize", ""}, {"ion", ""},
+}
+
+// step4 deletes suffixes in R2.
+func (s *stemmer) step4() {
+	r, ok := s.longest(step4Rules)
+	if !ok || !s.inR2(r.suffix) {
+		return
+	}
+	if r.suffix == "ion" {
+		before := len(s.w) - len(r.suffix) - 1
+		if before < 0 || (s.w[before] != 's' && s.w[before] != 't') {
+			return
+		}
+	}
+	s.replace(r.suffix, "")
+}
+
+// step5 deletes a final e, and the second l of a final ll, where the regions allow it.
+func (s *stemmer) step5() {
+	n := len(s.w)
+	switch {
+	case strings.HasSuffix(s.w, "e"):
+		if s.inR2("e") || (s.inR1("e") && !endsShortSyllable(s.w[:n-1])) {
+			s.w = s.w[:n-1]
+		}
+	case strings.HasSuffix(s.w, "ll"):
+		if s.inR2("l") {
+			s.w = s.w[:n-1]
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package text
+
+import "testing"
+
+// stemSamples are words and stems from the sample vocabulary on the Porter2 algorithm page, plus the
+// exceptions and the cases its rules single out.
+var stemSamples = map[string]string{
+	"consign": "consign", "consigned": "consign", "consigning": "consign", "consignment": "consign",
+	"consist": "consist", "consisted": "consist", "consistency": "consist", "consistent": "consist",
+	"consistently": "consist", "consisting": "consist", "consists": "consist",
+	"consolation": "consol", "consolations": "consol", "consolatory": "consolatori", "console": "consol",
+	"consoled": "consol", "consoles": "consol", "consolidate": "consolid", "consolidated": "consolid",
+	"consolidating": "consolid", "consoling": "consol", "consolingly": "consol", "consols": "consol",
+	"consonant": "conson", "consort": "consort", "consorted": "consort", "consorting": "consort",
+	"conspicuous": "conspicu", "conspicuously": "conspicu", "conspiracy": "conspiraci",
+	"conspirator": "conspir", "conspirators": "conspir", "conspire": "conspir", "conspired": "conspir",
+	"conspiring": "conspir", "constable": "constabl", "constables": "constabl", "constance": "constanc",
+	"constancy": "constanc", "constant": "constant",
+
+	"knack": "knack", "knackeries": "knackeri", "knacks": "knack", "knag": "knag", "knave": "knave",
+	"knaves": "knave", "knavish": "knavish", "kneaded": "knead", "kneading": "knead", "knee": "knee",
+	"kneel": "kneel", "kneeled": "kneel", "kneeling": "kneel", "kneels": "kneel", "knees": "knee",
+	"knell": "knell", "knelt": "knelt", "knew": "knew", "knick": "knick", "knif": "knif", "knife": "knife",
+	"knight": "knight", "knightly": "knight", "knights": "knight", "knit": "knit", "knits": "knit",
+	"knitted": "knit", "knitting": "knit", "knives": "knive", "knob": "knob", "knobs": "knob",
+	"knock": "knock", "knocked": "knock", "knocker": "knocker", "knockers": "knocker", "knocking": "knock",
+	"knocks": "knock", "knopp": "knopp", "knot": "knot", "knots": "knot",
+
+	// Exceptions, invariants and the special regions.
+	"skies": "sky", "dying": "die", "news": "news", "only": "onli", "early": "earli",
+	"succeed": "succeed", "herring": "herring", "generate": "generat", "communism": "communism",
+	// Possessives, plurals and -ed/-ing repairs.
+	"dog's": "dog", "dogs'": "dog", "caresses": "caress", "ties": "tie", "cries": "cri", "gas": "gas",
+	"gaps": "gap", "hopping": "hop", "hoping": "hope", "agreed": "agre", "luxuriating": "luxuri",
+	"tokenization": "token", "tokenizing": "token", "tokenized": "token", "happy": "happi", "cry": "cri",
+	"by": "by", "say": "say",
+}
+
+func TestStem(t *testing.T) {
+	for word, want := range stemSamples {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package text
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Stop words are the words nearly every English document uses, like "the", "is" and "of". They make up much of
+// every bag of words, so documents look alike just for being English, and dropping them leaves the words
+// that say what a document is about.
+
+// stopWordLists are the built-in lists. "english" is the Snowball English list, "minimal" only holds articles,
+// conjunctions, common prepositions and forms of "to be".
+var stopWordLists = map[string]string{
+	"english": `i me my myself we our ours ourselves you your yours yourself yourselves he him his himself she her
+		hers herself it its itself they them their theirs themselves what which who whom this that these those am is
+		are was were be been being have has had having do does did doing would should could ought i'm you're he's
+		she's it's we're they're i've you've we've they've i'd you'd he'd she'd we'd they'd i'll you'll he'll she'll
+		we'll they'll isn't aren't wasn't weren't hasn't haven't hadn't doesn't don't didn't won't wouldn't shan't
+		shouldn't can't cannot couldn't mustn't let's that's who's what's here's there's when's where's why's how's a
+		an the and but if or because as until while of at by for with about against between into through during
+		before after above below to from up down in out on off over under again further then once here there when
+		where why how all any both each few more most other some such no nor not only own same so than too very`,
+	"minimal": `a an the and or but nor so of at by for with to from in into on onto as is are was were be been
+		being`,
+}
+
+// StopWords is a set of words to drop, kept in the form the tokenizer gives them.
+type StopWords map[string]bool
+
+// LoadStopWords returns the built-in list called name, "none" or "" for no list, plus the words of the file at
+// path, one or more per line with # starting a comment.
+func LoadStopWords(name, path string) (StopWords, error) {
+	words := make(StopWords)
+
+	switch list, ok := stopWordLists[name]; {
+	case name == "" || name == "none":
+	case ok:
+		words.add(list)
+	default:
+		names := make([]string, 0, len(stopWordLists))
+		for n := range stopWordLists {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown stop word list %q (want none, %s)", name, strings.Join(names, ", "))
+	}
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			words.add(line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	return words, nil
+}
+
+// add puts the words of text into the set, normalized the way the tokenizer normalizes words so that "Don’t"
+// in a list still matches the token "don't".
+func (s StopWords) add(text string) {
+	for _, word := range segmentWords(text) {
+		s[StripPunctuation(FoldCase(word))] = true
+	}
+}
+
+// Filter is a Normalizer that drops stop words.
+func (s StopWords) Filter(token string) string {
+	if s[token] {
+		return ""
+	}
+
+	return token
+}
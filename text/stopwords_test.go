@@ -0,0 +1,54 @@
+package text
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStopWordContractions checks that contractions in the english list only drop themselves, never the word
+// they would spell without their apostrophe.
+func TestStopWordContractions(t *testing.T) {
+	words, err := LoadStopWords("english", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range []string{"he'll", "we'll", "she'll", "i'd", "can't", "won't", "don't"} {
+		if !words[w] {
+			t.Errorf("%q is not a stop word", w)
+		}
+	}
+	for _, w := range []string{"hell", "well", "shell", "id", "cant", "wont", "dont"} {
+		if words[w] {
+			t.Errorf("%q is a stop word", w)
+		}
+	}
+}
+
+func TestLoadStopWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stop.txt")
+	if err := os.WriteFile(path, []byte("Thou thee # archaic\n\nDoth’s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	words, err := LoadStopWords("minimal", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{"the", "thou", "thee", "doth's"} {
+		if !words[w] {
+			t.Errorf("%q is not a stop word", w)
+		}
+	}
+	if words["archaic"] {
+		t.Error("a word in a comment is a stop word")
+	}
+
+	if _, err := LoadStopWords("klingon", ""); err == nil {
+		t.Error("an unknown list loaded")
+	}
+	if words, err := LoadStopWords("none", ""); err != nil || len(words) != 0 {
+		t.Errorf("none: got %d words, %v", len(words), err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package text turns documents into the tokens the bag-of-words and tf-idf examples count: Unicode aware word
+// segmentation, case folding, stop word lists and the Porter2 stemmer.
+package text
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenizer turns a document into the tokens that become vocabulary entries.
+type Tokenizer interface {
+	Tokenize(doc string) []string
+}
+
+// Normalizer rewrites a single token. Returning an empty string drops the token.
+type Normalizer func(token string) string
+
+// WordTokenizer splits text into words and passes every word through its normalizers in order.
+//
+// Words are found roughly the way Unicode's word boundary rules (UAX #29) find them rather than by splitting on
+// spaces: a word is a run of letters, digits and combining marks, so tabs, newlines, repeated spaces and the
+// punctuation around a word never end up in a token. An apostrophe or a period between two letters stays
+// inside the word ("don't", "e.g"), as does a period or comma between two digits ("3.14", "1,000").
+// Ideographs (Chinese characters, Japanese kana) are written without spaces and become one token each.
+type WordTokenizer struct {
+	normalizers []Normalizer
+}
+
+func NewWordTokenizer(normalizers ...Normalizer) *WordTokenizer {
+	return &WordTokenizer{normalizers: normalizers}
+}
+
+func (t *WordTokenizer) Tokenize(doc string) []string {
+	var tokens []string
+	for _, word := range segmentWords(doc) {
+		for _, normalize := range t.normalizers {
+			if word = normalize(word); word == "" {
+				break
+			}
+		}
+		if word != "" {
+			tokens = append(tokens, word)
+		}
+	}
+
+	return tokens
+}
+
+// segmentWords returns the words of text in order, without the spaces and punctuation between them.
+func segmentWords(text string) []string {
+	runes := []rune(text)
+	var words []string
+	start := -1
+	for i, r := range runes {
+		switch {
+		case isIdeograph(r):
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			words = append(words, string(r))
+		case isWordRune(r):
+			if start < 0 {
+				start = i
+			}
+		case start >= 0 && joinsWord(runes, i):
+		default:
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r)
+}
+
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana)
+}
+
+// joinsWord reports whether the punctuation at runes[i] sits inside a word: apostrophes and periods between
+// letters, periods and commas between digits.
+func joinsWord(runes []rune, i int) bool {
+	if i == 0 || i+1 >= len(runes) {
+		return false
+	}
+	before, after := runes[i-1], runes[i+1]
+
+	switch r := runes[i]; {
+	case isApostrophe(r):
+		return unicode.IsLetter(before) && unicode.IsLetter(after)
+	case r == '.':
+		return (unicode.IsLetter(before) && unicode.IsLetter(after)) || (unicode.IsDigit(before) && unicode.IsDigit(after))
+	case r == ',':
+		return unicode.IsDigit(before) && unicode.IsDigit(after)
+	default:
+		return false
+	}
+}
+
+// FoldCase maps a token to lower case, with final sigma folded to sigma like Unicode case folding does.
+func FoldCase(token string) string {
+	return strings.Map(func(r rune) rune {
+		if r == 'ς' {
+			return 'σ'
+		}
+		return unicode.ToLower(r)
+	}, token)
+}
+
+// StripPunctuation removes the punctuation left inside a word, so "e.g" becomes "eg", but keeps the
+// separators of numbers like "3.14" and apostrophes between letters. Without its apostrophe "he'll" would be
+// "hell" and "we'll" "well", so it stays, written as ' whichever apostrophe the text used.
+func StripPunctuation(token string) string {
+	runes := []rune(token)
+	var b strings.Builder
+	for i, r := range runes {
+		inside := i > 0 && i+1 < len(runes)
+		switch {
+		case !unicode.IsPunct(r):
+		case inside && isApostrophe(r) && unicode.IsLetter(runes[i-1]) && unicode.IsLetter(runes[i+1]):
+			r = '\''
+		case inside && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1]):
+		default:
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// TokenizerOptions turns on the filters that can follow case folding and punctuation stripping.
+type TokenizerOptions struct {
+	// StopWords names a built-in stop word list, "none" or empty for none.
+	StopWords string
+	// StopWordsFile adds the stop words in a file to the list.
+	StopWordsFile string
+	// Stem reduces words to their Porter2 stem, after stop words are dropped so lists hold plain words.
+	Stem bool
+}
+
+// NewTokenizer always folds case and strips punctuation, so "The" and "the" or "words." and "words" are one
+// entry while "he'll" and "hell" stay two, then applies the filters opts asks for.
+func NewTokenizer(opts TokenizerOptions) (*WordTokenizer, error) {
+	normalizers := []Normalizer{FoldCase, StripPunctuation}
+
+	stopWords, err := LoadStopWords(opts.StopWords, opts.StopWordsFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(stopWords) > 0 {
+		normalizers = append(normalizers, stopWords.Filter)
+	}
+	if opts.Stem {
+		normalizers = append(normalizers, Stem)
+	}
+
+	return NewWordTokenizer(normalizers...), nil
+}
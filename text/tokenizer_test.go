@@ -0,0 +1,33 @@
+package text
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		doc  string
+		opts TokenizerOptions
+		want []string
+	}{
+		{"The process of  tokenizing\tis essential.", TokenizerOptions{}, []string{"the", "process", "of", "tokenizing", "is", "essential"}},
+		{"\"Words,\" she said; (words!)", TokenizerOptions{}, []string{"words", "she", "said", "words"}},
+		{"I'll, he'll and we’ll; not ill, hell or well.", TokenizerOptions{}, []string{"i'll", "he'll", "and", "we'll", "not", "ill", "hell", "or", "well"}},
+		{"'Tis the dogs' bowl", TokenizerOptions{}, []string{"tis", "the", "dogs", "bowl"}},
+		{"e.g. 3.14 or 1,000, not 1, 2", TokenizerOptions{}, []string{"eg", "3.14", "or", "1,000", "not", "1", "2"}},
+		{"ΟΔΟΣ odós", TokenizerOptions{}, []string{"οδοσ", "odós"}},
+		{"東京は大きい", TokenizerOptions{}, []string{"東", "京", "は", "大", "き", "い"}},
+		{"The dog is the best dog", TokenizerOptions{StopWords: "english"}, []string{"dog", "best", "dog"}},
+		{"Tokenization and tokenizing", TokenizerOptions{StopWords: "minimal", Stem: true}, []string{"token", "token"}},
+	}
+	for _, tt := range tests {
+		tokenizer, err := NewTokenizer(tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tokenizer.Tokenize(tt.doc); !slices.Equal(got, tt.want) {
+			t.Errorf("Tokenize(%q) with %+v = %q, want %q", tt.doc, tt.opts, got, tt.want)
+		}
+	}
+}
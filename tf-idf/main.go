@@ -1,9 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"math"
+	"os"
 	"strings"
+
+	"github.com/quinn-collins/text"
 )
 
 // Term Frequency (TF)
@@ -34,91 +39,139 @@ import (
 //   - Slow
 
 func main() {
+	var opts text.TokenizerOptions
+	flag.StringVar(&opts.StopWords, "stopwords", "none", "built-in stop word list to drop: none, english or minimal")
+	flag.StringVar(&opts.StopWordsFile, "stopwords-file", "", "file of extra stop words, one or more per line")
+	flag.BoolVar(&opts.Stem, "stem", false, "reduce words to their Porter2 stem so \"tokenization\" and \"tokenizing\" are one term")
+	corpusPattern := flag.String("corpus", "", "glob of text files to use as the documents instead of the examples, e.g. ../simple-embedding/corpora/*.txt")
+	lookup := flag.String("word", "dog", "word to look up in the documents")
+	modernize := flag.Bool("modernize", false, "rewrite Early Modern English spellings like \"sleepe\" and \"vpon\" before tokenizing")
+	spellingDictionary := flag.String("spelling-dictionary", "", "file of extra \"old modern\" spelling pairs for -modernize")
+	spellingReport := flag.Bool("spelling-report", false, "list the spellings -modernize merged")
+	wordNGramRange := flag.String("ngrams", "1", "word n-grams to use as terms, n or min-max, e.g. 1-2 for words and bigrams")
+	charNGramRange := flag.String("char-ngrams", "", "character n-grams of each word to use instead of word n-grams, n or min-max, e.g. 3-5")
+	flag.Parse()
+
+	tokenizer, err := text.NewTokenizer(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ngrams, err := text.NewNGramOptions(*wordNGramRange, *charNGramRange)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	doc1 := "My dog is the best dog that ever was a pet dog"
 	doc2 := "Vocabulary is the collection of unique words."
 	doc3 := "The process of tokenizing is essential in NLP."
 	corpus := []string{doc1, doc2, doc3}
+	if *corpusPattern != "" {
+		var paths []string
+		paths, corpus, err = text.LoadCorpus(*corpusPattern)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, path := range paths {
+			fmt.Printf("Document %d: %s\n", i+1, path)
+		}
+	}
 
-	// As always, tokenize and build out a dictionary of vocabulary
-	tokens := tokenize(corpus)
-	vocab := buildVocab(tokens)
+	// Folio spellings would otherwise be words of their own, "sleepe" never matching "sleep".
+	// The lookup word goes through the same steps as the documents so it can match them.
+	if *modernize {
+		modernizer, err := newModernizer(*spellingDictionary)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, doc := range corpus {
+			corpus[i] = modernizer.Modernize(doc)
+		}
+		if *spellingReport {
+			modernizer.Report(os.Stdout)
+			fmt.Println()
+		}
+		*lookup = modernizer.modernize(strings.ToLower(*lookup))
+	}
+	// A phrase like "process of" is looked up as a bigram. Character n-grams like "<do" are looked up as given.
+	if words := tokenizer.Tokenize(*lookup); len(words) > 0 && ngrams.CharMaxN == 0 {
+		*lookup = strings.Join(words, " ")
+	}
 
-	fmt.Println("Vocabulary:", vocab)
+	// As always, tokenize and build out a dictionary of vocabulary.
+	// With -ngrams or -char-ngrams the terms are runs of words or letters rather than single words, built from
+	// the words -stopwords leaves.
+	tokens := text.Features(text.TokenizeAll(corpus, tokenizer), ngrams)
+	vocab := text.BuildVocab(tokens)
+
+	// Vectors over a whole play's vocabulary run to thousands of numbers, only print the small ones.
+	printVectors := len(vocab) <= maxPrintedTerms
+	if printVectors {
+		fmt.Println("Vocabulary:", vocab)
+	} else {
+		fmt.Printf("Vocabulary: %d terms\n", len(vocab))
+	}
 
 	// Build a slice of ints that represents how many times each word shows up in our vocab
 	df := documentFrequency(tokens, vocab)
-	fmt.Println("\nDF:", df)
+	if printVectors {
+		fmt.Println("\nDF:", df)
+	}
 
 	// Build a slice of floats that represents how rare all of our words are across all of our documents
 	idf := inverseDocumentFrequency(sliceIntToFloat(df), len(corpus))
-	fmt.Println("\nIDF:", idf)
+	if printVectors {
+		fmt.Println("\nIDF:", idf)
+	}
 
 	// Calculate tf_idf for each document
+	// Both are sparse, a play uses a few thousand of the terms of all three plays.
+	tfs := make([]text.SparseVector, len(tokens))
+	tfIDFs := make([]text.SparseVector, len(tokens))
 	for i, doc := range tokens {
 		// get a frequency list for each word according to our corpus vocab
-		tf := termFrequency(doc, vocab)
-		// sum the tf and idf together and return a slice of floats
-		tfIDFVec := tfIDF(sliceIntToFloat(tf), idf)
+		tfs[i] = text.SparseCounts(doc, vocab)
+		// multiply the tf and idf together
+		tfIDFs[i] = tfIDF(tfs[i], idf)
 
-		fmt.Printf("Document %d TF: %v\n", i, tf)
-		fmt.Printf("Document %d TF_IDF: %v\n", i, tfIDFVec)
+		if printVectors {
+			fmt.Printf("Document %d TF: %v\n", i+1, tfs[i].Dense())
+			fmt.Printf("Document %d TF_IDF: %v\n", i+1, tfIDFs[i].Dense())
+		}
 	}
 
 	// Example
-	word := "dog"
+	word := *lookup
 
 	wordIdx, ok := vocab[word]
 	if !ok {
-		fmt.Printf("The word %s is not in the vocab", word)
+		fmt.Printf("The word %s is not in the vocab\n", word)
+		return
 	}
 
 	// Find documents containing the word
-	for i, doc := range tokens {
-		tf := termFrequency(doc, vocab)
-		if containsWord(sliceIntToFloat(tf), wordIdx) {
-			fmt.Printf("Document %d contains %s\n", i, word)
+	for i, tf := range tfs {
+		if tf.At(wordIdx) > 0 {
+			fmt.Printf("Document %d contains %s\n", i+1, word)
 		}
 	}
 
 	// Check how many times the word appears
-	for i, doc := range tokens {
-		tf := termFrequency(doc, vocab)
-		count := tf[wordIdx]
-
-		if count > 0 {
-			fmt.Printf("Document %d: %s appears %d times\n", i, word, count)
+	for i, tf := range tfs {
+		if count := tf.At(wordIdx); count > 0 {
+			fmt.Printf("Document %d: %s appears %d times\n", i+1, word, int(count))
 		}
 	}
 
 	// Importance check across the corpus
-	for i, doc := range tokens {
-		tf := termFrequency(doc, vocab)
-		tfIDFVec := tfIDF(sliceIntToFloat(tf), idf)
-
-		score := tfIDFVec[wordIdx]
-		if score > 0 {
-			fmt.Printf("Document %d: TF-IDF(%s) = %.4f\n", i, word, score)
+	for i, tfIDFVec := range tfIDFs {
+		if score := tfIDFVec.At(wordIdx); score > 0 {
+			fmt.Printf("Document %d: TF-IDF(%s) = %.4f\n", i+1, word, score)
 		}
 	}
-
-}
-
-func containsWord(tf []float64, wordIdx int) bool {
-	return tf[wordIdx] > 0
 }
 
-// termFrequency returns a slice of ints that represents how often each term shows up in a document
-func termFrequency(doc []string, vocab map[string]int) []int {
-	tf := make([]int, len(vocab))
-
-	for _, word := range doc {
-		if i, ok := vocab[word]; ok {
-			tf[i]++
-		}
-	}
-
-	return tf
-}
+// maxPrintedTerms is the largest vocabulary whose vectors are printed.
+const maxPrintedTerms = 50
 
 func inverseDocumentFrequency(df []float64, numDocs int) []float64 {
 	idf := make([]float64, len(df))
@@ -131,13 +184,15 @@ func inverseDocumentFrequency(df []float64, numDocs int) []float64 {
 	return idf
 }
 
-func tfIDF(tf []float64, idf []float64) []float64 {
-	result := make([]float64, len(tf))
-	for i := range tf {
-		result[i] = tf[i] * idf[i]
+// tfIDF weights every term of a document's term frequencies by its idf. Terms every document uses have an
+// idf of 0 and drop out of the vector.
+func tfIDF(tf text.SparseVector, idf []float64) text.SparseVector {
+	weighted := make(map[int]float64, len(tf.Indices))
+	for k, i := range tf.Indices {
+		weighted[i] = tf.Values[k] * idf[i]
 	}
 
-	return result
+	return text.NewSparseVector(tf.Dim, weighted)
 }
 
 // documentFrequency returns a slice representing how many times each word in the vocabulary shows up in all of the documents.
@@ -157,31 +212,6 @@ func documentFrequency(tokens [][]string, vocab map[string]int) []int {
 	return df
 }
 
-// tokenize returns tokens as space-delimited slices of strings of a corpus.
-func tokenize(corpus []string) [][]string {
-	var tokens [][]string
-	for _, doc := range corpus {
-		tokens = append(tokens, strings.Split(doc, " "))
-	}
-
-	return tokens
-}
-
-func buildVocab(tokens [][]string) map[string]int {
-	vocab := make(map[string]int)
-	index := 0
-
-	for _, doc := range tokens {
-		for _, word := range doc {
-			if _, exists := vocab[word]; !exists {
-				vocab[word] = index
-				index++
-			}
-		}
-	}
-	return vocab
-}
-
 func sliceIntToFloat(s []int) []float64 {
 	floatSlice := make([]float64, len(s))
 	for i, v := range s {
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// The Folio spells words the way its printers did around 1600: "Vpon", "haue", "againe", "sleepe", "Iust".
+// Exact-match methods see each of those as a word of its own, so "sleep" never finds "sleepe".
+// Modernizer rewrites the text with a few spelling rules of the period before it is tokenized:
+//
+//	u and v    v at the start of a word before a consonant is u ("vpon", "vs"), u between vowels is v ("haue")
+//	i and j    i at the start of a word before a vowel is j ("iust", "ioy")
+//	y          y between a vowel and a consonant is i ("voyce", "neyther")
+//	final e    the e printers added after consonant clusters, doubled letters and long vowels goes
+//	           ("againe", "sleepe", "thinke", "sinne", "lesse")
+//
+// Words the rules get wrong or can't reach are looked up in a variant dictionary first.
+
+// spellingVariants maps old spellings to modern ones. A word mapped to itself is kept away from the rules.
+var spellingVariants = map[string]string{
+	// Irregular spellings.
+	"doe": "do", "goe": "go", "mee": "me", "wee": "we", "hee": "he", "shee": "she", "bee": "be",
+	"beene": "been", "onely": "only", "sayes": "says", "dayes": "days", "wayes": "ways", "noyse": "noise",
+	"yong": "young", "shew": "show", "shewes": "shows", "shewne": "shown", "murther": "murder",
+	"mistris": "mistress", "euill": "evil", "deuill": "devil", "deuils": "devils",
+	"maiesty": "majesty", "maiestie": "majesty", "iniury": "injury", "iniurie": "injury",
+	"serue": "serve", "deserue": "deserve", "selues": "selves", "themselues": "themselves",
+	"yourselues": "yourselves", "ourselues": "ourselves", "ile": "i'll", "vnkle": "uncle",
+	"heere": "here", "deere": "dear", "beleeue": "believe", "beleeues": "believes", "beleeued": "believed",
+	// Modern words the rules would break.
+	"create": "create", "value": "value", "ease": "ease", "peace": "peace", "cheese": "cheese",
+	"ionian": "ionian", "iota": "iota", "ion": "ion", "always": "always", "anne": "anne",
+	"payment": "payment", "greyhound": "greyhound", "greyhounds": "greyhounds",
+	// Names the rules would respell.
+	"seyton": "seyton", "reynol": "reynol", "reynoldo": "reynoldo",
+	// Old spellings where the y is still written today.
+	"ioyfull": "joyful", "ioyfully": "joyfully", "imployment": "employment", "playd": "played",
+}
+
+var wordPattern = regexp.MustCompile(`\p{L}+`)
+
+// Modernizer rewrites Early Modern English spellings and remembers which spellings it merged.
+type Modernizer struct {
+	variants map[string]string
+	// merged counts, for every modern spelling, the old spellings rewritten to it.
+	merged map[string]map[string]int
+	// words counts every word after rewriting, so the report can tell how often the modern spelling was already used.
+	words map[string]int
+}
+
+// newModernizer uses the built-in variant dictionary plus the pairs in the file at path, one "old modern" pair
+// per line with # starting a comment.
+func newModernizer(path string) (*Modernizer, error) {
+	m := &Modernizer{
+		variants: make(map[string]string, len(spellingVariants)),
+		merged:   make(map[string]map[string]int),
+		words:    make(map[string]int),
+	}
+	for old, modern := range spellingVariants {
+		m.variants[old] = modern
+	}
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			text, _, _ := strings.Cut(scanner.Text(), "#")
+			fields := strings.Fields(strings.ToLower(text))
+			switch len(fields) {
+			case 0:
+			case 2:
+				m.variants[fields[0]] = fields[1]
+			default:
+				return nil, fmt.Errorf("%s:%d: want an old and a modern spelling", path, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	return m, nil
+}
+
+// Modernize rewrites every word of text, keeping the rest of it and the capitalization of each word as they are.
+func (m *Modernizer) Modernize(text string) string {
+	return wordPattern.ReplaceAllStringFunc(text, func(word string) string {
+		lower := strings.ToLower(word)
+		modern := m.modernize(lower)
+		m.words[modern]++
+		if modern == lower {
+			return word
+		}
+
+		if m.merged[modern] == nil {
+			m.merged[modern] = make(map[string]int)
+		}
+		m.merged[modern][lower]++
+
+		return matchCase(modern, word)
+	})
+}
+
+func (m *Modernizer) modernize(word string) string {
+	if modern, ok := m.variants[word]; ok {
+		return modern
+	}
+
+	w := []rune(word)
+	swapUV(w)
+	swapIJ(w)
+	swapY(w)
+	modern := string(w)
+
+	// A plural or third person -s follows the same final e: "sleepes" is "sleeps". After s, x, z, ch and sh the
+	// -es is still spelled that way, "losses" and "kisses" keep it.
+	if base, ok := strings.CutSuffix(modern, "es"); ok && len(base) > 2 && !hasSibilantEnding(base) {
+		if trimmed := dropFinalE(base + "e"); trimmed != base+"e" {
+			return trimmed + "s"
+		}
+		return modern
+	}
+
+	return dropFinalE(modern)
+}
+
+func hasSibilantEnding(word string) bool {
+	return strings.HasSuffix(word, "s") || strings.HasSuffix(word, "x") || strings.HasSuffix(word, "z") ||
+		strings.HasSuffix(word, "ch") || strings.HasSuffix(word, "sh")
+}
+
+func isSpellingVowel(r rune) bool {
+	return strings.ContainsRune("aeiou", r)
+}
+
+func isConsonant(r rune) bool {
+	return unicode.IsLetter(r) && !isSpellingVowel(r) && r != 'y'
+}
+
+// swapUV: "vpon" and "vs" start with u, "haue" and "Reuolt" have a v between their vowels.
+func swapUV(w []rune) {
+	if len(w) > 1 && w[0] == 'v' && isConsonant(w[1]) {
+		w[0] = 'u'
+	}
+	for i := 1; i+1 < len(w); i++ {
+		if w[i] == 'u' && isSpellingVowel(w[i-1]) && isSpellingVowel(w[i+1]) {
+			w[i] = 'v'
+		}
+	}
+}
+
+// swapIJ: "iust" and "Ioy" start with j. A lone "i" is the pronoun.
+func swapIJ(w []rune) {
+	if len(w) > 1 && w[0] == 'i' && isSpellingVowel(w[1]) {
+		w[0] = 'j'
+	}
+}
+
+// swapY: "voyce" and "neyther" have an i where the y sits between a vowel and a consonant. Before s and w the y
+// stays, "days" and "always" are spelled that way today.
+func swapY(w []rune) {
+	for i := 1; i+1 < len(w); i++ {
+		if w[i] == 'y' && isSpellingVowel(w[i-1]) && isConsonant(w[i+1]) && w[i+1] != 's' && w[i+1] != 'w' {
+			w[i] = 'i'
+		}
+	}
+}
+
+// dropFinalE removes the silent e the Folio adds where modern spelling has none:
+//
+//	after a consonant and k or p           "thinke", "sicke", "helpe"
+//	after ll, ss, ff or zz                 "lesse", "stuffe"
+//	after another doubled consonant, which is undoubled   "sinne", "warre", but "adde" and "egge" keep it
+//	after lf or wn                         "selfe", "owne"
+//	after ai, ea, ee, oa, oi or oo and one consonant      "againe", "heare", "sleepe", "foole"
+//
+// Long vowels before c, s, v, z or th keep their e, like "peace", "cheese" and "sleeve" do today.
+func dropFinalE(word string) string {
+	w := []rune(word)
+	n := len(w)
+	if n < 4 || w[n-1] != 'e' {
+		return word
+	}
+	a, b, c := w[n-4], w[n-3], w[n-2]
+
+	switch {
+	case (c == 'k' || c == 'p') && isConsonant(b) && b != c:
+		return string(w[:n-1])
+	case b == c && (strings.ContainsRune("lsfz", c) || n == 4):
+		return string(w[:n-1])
+	case b == c && isConsonant(c):
+		return string(w[:n-2])
+	case (b == 'l' && c == 'f') || (b == 'w' && c == 'n'):
+		return string(w[:n-1])
+	case isConsonant(c) && !strings.ContainsRune("csvzh", c) && isLongVowel(a, b):
+		return string(w[:n-1])
+	}
+
+	return word
+}
+
+func isLongVowel(a, b rune) bool {
+	switch string([]rune{a, b}) {
+	case "ai", "ea", "ee", "oa", "oi", "oo":
+		return true
+	}
+
+	return false
+}
+
+// matchCase gives modern the capitalization of original: all upper case, a capital first letter, or none.
+func matchCase(modern, original string) string {
+	if strings.ToUpper(original) == original && len([]rune(original)) > 1 {
+		return strings.ToUpper(modern)
+	}
+	if first := []rune(original)[0]; unicode.IsUpper(first) {
+		r := []rune(modern)
+		r[0] = unicode.ToUpper(r[0])
+		return string(r)
+	}
+
+	return modern
+}
+
+// Report writes the merges Modernize made, most rewritten spelling first, e.g.
+//
+//	sleep    <- sleepe 23                (14 already spelled sleep)
+func (m *Modernizer) Report(w io.Writer) {
+	type merge struct {
+		modern   string
+		variants []string
+		total    int
+	}
+
+	var merges []merge
+	for modern, variants := range m.merged {
+		mg := merge{modern: modern}
+		for v, n := range variants {
+			mg.variants = append(mg.variants, v)
+			mg.total += n
+		}
+		sort.Slice(mg.variants, func(i, j int) bool {
+			if variants[mg.variants[i]] != variants[mg.variants[j]] {
+				return variants[mg.variants[i]] > variants[mg.variants[j]]
+			}
+			return mg.variants[i] < mg.variants[j]
+		})
+		merges = append(merges, mg)
+	}
+	sort.Slice(merges, func(i, j int) bool {
+		if merges[i].total != merges[j].total {
+			return merges[i].total > merges[j].total
+		}
+		return merges[i].modern < merges[j].modern
+	})
+
+	fmt.Fprintf(w, "%d spellings merged into %d modern words\n", len(m.mergedSpellings()), len(merges))
+	for _, mg := range merges {
+		parts := make([]string, 0, len(mg.variants))
+		for _, v := range mg.variants {
+			parts = append(parts, fmt.Sprintf("%s %d", v, m.merged[mg.modern][v]))
+		}
+		line := fmt.Sprintf("%-12s <- %s", mg.modern, strings.Join(parts, ", "))
+		if already := m.words[mg.modern] - mg.total; already > 0 {
+			line = fmt.Sprintf("%-40s (%d already spelled %s)", line, already, mg.modern)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func (m *Modernizer) mergedSpellings() map[string]bool {
+	spellings := make(map[string]bool)
+	for _, variants := range m.merged {
+		for v := range variants {
+			spellings[v] = true
+		}
+	}
+
+	return spellings
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestModernize(t *testing.T) {
+	tests := map[string]string{
+		// The rules.
+		"vpon": "upon", "vs": "us", "haue": "have", "iust": "just", "ioy": "joy", "voyce": "voice",
+		"neyther": "neither", "ioyne": "join", "ayre": "air", "againe": "again", "sleepe": "sleep",
+		"sleepes": "sleeps", "thinke": "think", "sinne": "sin", "warre": "war", "lesse": "less", "selfe": "self",
+		"owne": "own", "foole": "fool", "adde": "add", "egge": "egg",
+		// Words the rules leave alone.
+		"i": "i", "days": "days", "always": "always", "losses": "losses", "kisses": "kisses",
+		"peace": "peace", "cheese": "cheese", "sleeve": "sleeve", "i'll": "i'll", "ill": "ill",
+		// The dictionary.
+		"ile": "i'll", "heere": "here", "beleeue": "believe", "doe": "do", "ioyfull": "joyful", "playd": "played",
+		"payment": "payment", "greyhounds": "greyhounds", "ionian": "ionian",
+		// Names.
+		"seyton": "seyton", "reynol": "reynol", "reynoldo": "reynoldo", "macbeth": "macbeth", "hamlet": "hamlet",
+	}
+
+	m, err := newModernizer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for old, want := range tests {
+		if got := m.modernize(old); got != want {
+			t.Errorf("modernize(%q) = %q, want %q", old, got, want)
+		}
+	}
+}
+
+func TestModernizeKeepsCase(t *testing.T) {
+	m, err := newModernizer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const text, want = "Vpon the Heath, SLEEPE; Ile doe it.", "Upon the Heath, SLEEP; I'll do it."
+	if got := m.Modernize(text); got != want {
+		t.Errorf("Modernize(%q) = %q, want %q", text, got, want)
+	}
+}
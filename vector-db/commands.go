@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseArgs parses fs wherever its flags appear among the positional arguments, so both
+// `query -limit 3 delicious food` and `query delicious food -limit 3` work, and returns the positional ones.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		// ExitOnError flag sets exit on their own.
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: vector-db %s %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// runIngest streams a file into the collection, or the demo documents without a file.
+// The file path is the source, so ingesting an edited file again updates its points in place.
+func (app *Application) runIngest(args []string) {
+	fs := newFlagSet("ingest", "[file]")
+	inputFormat := fs.String("input-format", InputAuto, "file format: auto (from the extension), lines (one document per line), jsonl or csv")
+	textField := fs.String("text-field", "text", "JSONL or CSV field holding the text to embed, every other field becomes payload")
+	schemaSpec := fs.String("schema", "", "payload fields every row must have, e.g. genre:string,year:int,rating:float? where ? marks an optional field")
+	batchSize := fs.Int("batch-size", 256, "rows embedded and stored at a time")
+	positional := parseArgs(fs, args)
+	if len(positional) > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if len(positional) == 0 {
+		app.embedVectorsAndStoreInDB(demoSource, demoDocuments())
+		return
+	}
+
+	path := positional[0]
+	format, err := detectInputFormat(path, *inputFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	schema, err := parseSchema(*schemaSpec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *batchSize <= 0 {
+		log.Fatalf("-batch-size must be positive, got %d", *batchSize)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("failed to read %s: %v", path, err)
+	}
+	defer f.Close()
+	reader, err := newDocumentReader(f, format, *textField, schema)
+	if err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+
+	ctx := context.Background()
+	in, err := newIngester(ctx, app.store, getEmbedder(app.embeddingBaseURL), app.collection, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rowErrors := 0
+	batch := make([]Document, 0, *batchSize)
+	for {
+		doc, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			log.Printf("%s: skipping %v", path, rowErr)
+			rowErrors++
+			continue
+		}
+		if err != nil {
+			log.Fatalf("failed to read %s: %v", path, err)
+		}
+
+		batch = append(batch, doc)
+		if len(batch) == *batchSize {
+			if err := in.add(ctx, batch); err != nil {
+				log.Fatal(err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := in.add(ctx, batch); err != nil {
+		log.Fatal(err)
+	}
+
+	if rowErrors > 0 {
+		// Points for skipped rows would otherwise be deleted as if their documents were gone.
+		stats := in.stats
+		fmt.Fprintf(os.Stderr, "%s: %s, %d rows skipped, not deleting anything until they are fixed\n", app.collection, stats, rowErrors)
+		os.Exit(1)
+	}
+	stats, err := in.finish(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", app.collection, stats)
+}
+
+func (app *Application) runQuery(args []string) {
+	fs := newFlagSet("query", "<text>")
+	limit := fs.Uint64("limit", 5, "number of results")
+	filterExpr := fs.String("filter", "", "only return points whose payload passes this filter, e.g. genre=food, genre!=history or JSON like {\"must\":[{\"key\":\"genre\",\"match\":\"food\"}]}")
+	var threshold *float32
+	fs.Func("score-threshold", "drop results scoring below this, or above it for euclid distance", func(s string) error {
+		v, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return err
+		}
+		t := float32(v)
+		threshold = &t
+		return nil
+	})
+	positional := parseArgs(fs, args)
+
+	query := strings.Join(positional, " ")
+	if query == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	filter, err := parseFilter(*filterExpr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results := app.queryQdrant(query, SearchQuery{
+		Limit:          *limit,
+		Filter:         filter,
+		ScoreThreshold: threshold,
+	})
+	printResults(os.Stdout, app.format, query, results)
+}
+
+func (app *Application) runDelete(args []string) {
+	fs := newFlagSet("delete", "-filter <filter>")
+	filterExpr := fs.String("filter", "", "delete the points whose payload passes this filter, same syntax as query -filter")
+	if positional := parseArgs(fs, args); len(positional) > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	filter, err := parseFilter(*filterExpr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if filter == nil {
+		// An empty filter matches everything, dropping the collection says that more clearly.
+		log.Fatal("delete needs a -filter, use collections drop to delete everything")
+	}
+
+	ctx := context.Background()
+	points, err := app.store.Scroll(ctx, app.collection, filter, false)
+	if err != nil {
+		log.Fatalf("failed to find points: %v", err)
+	}
+	ids := make([]string, 0, len(points))
+	for _, p := range points {
+		ids = append(ids, p.ID)
+	}
+	if len(ids) > 0 {
+		if err := app.store.Delete(ctx, app.collection, ids); err != nil {
+			log.Fatalf("failed to delete points: %v", err)
+		}
+	}
+
+	fmt.Printf("deleted %d points from %s\n", len(ids), app.collection)
+}
+
+func (app *Application) runCollections(args []string) {
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		log.Fatal("usage: vector-db collections list|create|drop")
+	}
+	switch args[0] {
+	case "list":
+		names, err := app.store.Collections(ctx)
+		if err != nil {
+			log.Fatalf("failed to list collections: %v", err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	case "create":
+		fs := newFlagSet("collections create", "<name>")
+		size := fs.Uint64("size", 3072, "vector dimension, "+EmbeddingModel+" returns 3072")
+		distanceName := fs.String("distance", DistanceCosine.String(), "distance: cosine, dot or euclid")
+		positional := parseArgs(fs, args[1:])
+		if len(positional) != 1 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		distance, err := parseDistance(*distanceName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *size == 0 {
+			log.Fatal("-size must be positive")
+		}
+		if err := app.store.CreateCollection(ctx, positional[0], CollectionConfig{VectorSize: *size, Distance: distance}); err != nil {
+			log.Fatalf("failed to create collection: %v", err)
+		}
+		fmt.Printf("created %s\n", positional[0])
+	case "drop":
+		fs := newFlagSet("collections drop", "<name>")
+		positional := parseArgs(fs, args[1:])
+		if len(positional) != 1 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		if err := app.store.DropCollection(ctx, positional[0]); err != nil {
+			log.Fatalf("failed to drop collection: %v", err)
+		}
+		fmt.Printf("dropped %s\n", positional[0])
+	default:
+		log.Fatalf("unknown collections command %q (want list, create or drop)", args[0])
+	}
+}
+
+func (app *Application) runStats(args []string) {
+	fs := newFlagSet("stats", "")
+	if positional := parseArgs(fs, args); len(positional) > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	config, err := app.store.Collection(ctx, app.collection)
+	if errors.Is(err, ErrCollectionNotFound) {
+		log.Fatalf("collection %s does not exist, create it with ingest or collections create", app.collection)
+	}
+	if err != nil {
+		log.Fatalf("failed to read collection: %v", err)
+	}
+	count, err := app.store.Count(ctx, app.collection, nil)
+	if err != nil {
+		log.Fatalf("failed to count points: %v", err)
+	}
+
+	fmt.Printf("collection  %s\n", app.collection)
+	fmt.Printf("points      %d\n", count)
+	fmt.Printf("vector size %d\n", config.VectorSize)
+	fmt.Printf("distance    %s\n", config.Distance)
+}
+
+// runConfig prints the configuration vector-db would use, so a deployment can be checked without connecting.
+func runConfig(config *Config, args []string) {
+	fs := newFlagSet("config", "show")
+	positional := parseArgs(fs, args)
+	if len(positional) != 1 || positional[0] != "show" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	config.show(os.Stdout)
+}
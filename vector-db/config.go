@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// Config is where vector-db finds Qdrant and which collection it works on. Every setting is resolved from,
+// in rising order of precedence, its default, the config file, an environment variable and a flag.
+type Config struct {
+	Collection    string
+	Host          string
+	Port          int
+	APIKey        string
+	TLS           bool
+	CACert        string
+	TLSSkipVerify bool
+	// Timeout bounds every request to Qdrant, 0 waits as long as the caller's context allows.
+	Timeout time.Duration
+
+	// sources records where each setting came from, by its config file key.
+	sources map[string]string
+}
+
+// configSetting ties a config file key to its environment variable, flag and field.
+type configSetting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	// bare values are written without quotes by show.
+	bare bool
+	get  func(c *Config) string
+	set  func(c *Config, value string) error
+}
+
+var configSettings = []configSetting{
+	{
+		key: "collection", env: "VECTOR_DB_COLLECTION", flag: "collection", usage: "collection to work on",
+		get: func(c *Config) string { return c.Collection },
+		set: func(c *Config, v string) error { c.Collection = v; return nil },
+	},
+	{
+		key: "qdrant.host", env: "QDRANT_HOST", flag: "qdrant-host", usage: "Qdrant host name",
+		get: func(c *Config) string { return c.Host },
+		set: func(c *Config, v string) error { c.Host = v; return nil },
+	},
+	{
+		key: "qdrant.port", bare: true, env: "QDRANT_PORT", flag: "qdrant-port", usage: "Qdrant gRPC port",
+		get: func(c *Config) string { return strconv.Itoa(c.Port) },
+		set: func(c *Config, v string) (err error) { c.Port, err = strconv.Atoi(v); return err },
+	},
+	{
+		key: "qdrant.api_key", env: "QDRANT_API_KEY", flag: "qdrant-api-key", usage: "Qdrant API key, prefer the environment variable so it stays out of shell history", secret: true,
+		get: func(c *Config) string { return c.APIKey },
+		set: func(c *Config, v string) error { c.APIKey = v; return nil },
+	},
+	{
+		key: "qdrant.tls", bare: true, env: "QDRANT_TLS", flag: "qdrant-tls", usage: "connect to Qdrant over TLS",
+		get: func(c *Config) string { return strconv.FormatBool(c.TLS) },
+		set: func(c *Config, v string) (err error) { c.TLS, err = strconv.ParseBool(v); return err },
+	},
+	{
+		key: "qdrant.ca_cert", env: "QDRANT_CA_CERT", flag: "qdrant-ca-cert", usage: "PEM file of the CA that signed Qdrant's certificate, if the system roots don't cover it",
+		get: func(c *Config) string { return c.CACert },
+		set: func(c *Config, v string) error { c.CACert = v; return nil },
+	},
+	{
+		key: "qdrant.tls_skip_verify", bare: true, env: "QDRANT_TLS_SKIP_VERIFY", flag: "qdrant-tls-skip-verify", usage: "accept any certificate from Qdrant, only for testing",
+		get: func(c *Config) string { return strconv.FormatBool(c.TLSSkipVerify) },
+		set: func(c *Config, v string) (err error) { c.TLSSkipVerify, err = strconv.ParseBool(v); return err },
+	},
+	{
+		key: "qdrant.timeout", env: "QDRANT_TIMEOUT", flag: "qdrant-timeout", usage: "limit for each request to Qdrant, e.g. 10s, 0 for none",
+		get: func(c *Config) string { return c.Timeout.String() },
+		set: func(c *Config, v string) (err error) { c.Timeout, err = time.ParseDuration(v); return err },
+	},
+}
+
+func defaultConfig() *Config {
+	c := &Config{
+		Collection: CollectionName,
+		Host:       "localhost",
+		Port:       6334,
+		Timeout:    30 * time.Second,
+		sources:    make(map[string]string),
+	}
+	for _, s := range configSettings {
+		c.sources[s.key] = "default"
+	}
+
+	return c
+}
+
+// registerConfigFlags adds a flag for every setting. Flag defaults are left empty so that only flags
+// given on the command line override the file and the environment.
+func registerConfigFlags(fs *flag.FlagSet) map[string]*string {
+	values := make(map[string]*string, len(configSettings))
+	for _, s := range configSettings {
+		values[s.flag] = fs.String(s.flag, "", fmt.Sprintf("%s (%s, %s in the config file)", s.usage, s.env, s.key))
+	}
+
+	return values
+}
+
+// loadConfig resolves the configuration. path is the config file, empty to use VECTOR_DB_CONFIG if set,
+// and flagValues holds the flags registered by registerConfigFlags.
+func loadConfig(path string, fs *flag.FlagSet, flagValues map[string]*string) (*Config, error) {
+	c := defaultConfig()
+
+	if path == "" {
+		path = os.Getenv("VECTOR_DB_CONFIG")
+	}
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range configSettings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(c, v); err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", s.env, v, err)
+			}
+			c.sources[s.key] = "env " + s.env
+		}
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range configSettings {
+		if set[s.flag] {
+			v := *flagValues[s.flag]
+			if err := s.set(c, v); err != nil {
+				return nil, fmt.Errorf("invalid -%s %q: %w", s.flag, v, err)
+			}
+			c.sources[s.key] = "flag -" + s.flag
+		}
+	}
+
+	return c, c.validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	defer f.Close()
+
+	values, err := parseTOML(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	byKey := make(map[string]configSetting, len(configSettings))
+	for _, s := range configSettings {
+		byKey[s.key] = s
+	}
+	for _, v := range values {
+		s, ok := byKey[v.key]
+		if !ok {
+			return fmt.Errorf("%s:%d: unknown setting %s", path, v.line, v.key)
+		}
+		if err := s.set(c, v.value); err != nil {
+			return fmt.Errorf("%s:%d: invalid %s %q: %w", path, v.line, v.key, v.value, err)
+		}
+		c.sources[s.key] = "file " + path
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	if c.Collection == "" {
+		errs = append(errs, errors.New("collection is empty"))
+	}
+	if c.Host == "" {
+		errs = append(errs, errors.New("qdrant.host is empty"))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("qdrant.port %d is not between 1 and 65535", c.Port))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("qdrant.timeout %s is negative", c.Timeout))
+	}
+	if !c.TLS && (c.CACert != "" || c.TLSSkipVerify) {
+		errs = append(errs, errors.New("qdrant.ca_cert and qdrant.tls_skip_verify need qdrant.tls"))
+	}
+	if c.CACert != "" {
+		if _, err := os.Stat(c.CACert); err != nil {
+			errs = append(errs, fmt.Errorf("qdrant.ca_cert: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// show writes the configuration in config file form, with the API key redacted and where each value came from.
+func (c *Config) show(w io.Writer) {
+	section := ""
+	for _, s := range configSettings {
+		sec, name, ok := strings.Cut(s.key, ".")
+		if !ok {
+			sec, name = "", s.key
+		}
+		if sec != section {
+			fmt.Fprintf(w, "\n[%s]\n", sec)
+			section = sec
+		}
+
+		value := s.get(c)
+		if s.secret && value != "" {
+			value = "<redacted>"
+		}
+		if !s.bare {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(w, "%-16s = %-28s # %s\n", name, value, c.sources[s.key])
+	}
+}
+
+// qdrantConfig builds the client config, with the timeout applied to every call that has no earlier deadline.
+func (c *Config) qdrantConfig() (*qdrant.Config, error) {
+	config := &qdrant.Config{
+		Host:   c.Host,
+		Port:   c.Port,
+		APIKey: c.APIKey,
+		UseTLS: c.TLS,
+	}
+	if c.APIKey != "" && !c.TLS {
+		log.Printf("warning: sending the Qdrant API key without TLS")
+	}
+
+	if c.TLS && (c.CACert != "" || c.TLSSkipVerify) {
+		tlsConfig := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: c.TLSSkipVerify,
+		}
+		if c.CACert != "" {
+			pem, err := os.ReadFile(c.CACert)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%s holds no PEM certificates", c.CACert)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		config.TLSConfig = tlsConfig
+	}
+
+	if c.Timeout > 0 {
+		timeout := c.Timeout
+		config.GrpcOptions = append(config.GrpcOptions, grpc.WithUnaryInterceptor(
+			func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+				if _, ok := ctx.Deadline(); !ok {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, timeout)
+					defer cancel()
+				}
+				return invoker(ctx, method, req, reply, cc, opts...)
+			}))
+	}
+
+	return config, nil
+}
+
+type tomlValue struct {
+	key   string
+	value string
+	line  int
+}
+
+// parseTOML reads the small part of TOML a config file needs: [section] headers, key = value pairs and
+// # comments. Values are basic or literal strings, integers or booleans, and come back as plain strings
+// under section.key. Arrays, tables inside tables and multi-line strings are not supported.
+func parseTOML(r io.Reader) ([]tomlValue, error) {
+	var values []tomlValue
+	seen := make(map[string]int)
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") || strings.HasPrefix(text, "[[") {
+				return nil, fmt.Errorf("line %d: invalid section header %s", line, text)
+			}
+			section = strings.TrimSpace(text[1 : len(text)-1])
+			continue
+		}
+
+		key, raw, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", line)
+		}
+		key, raw = strings.TrimSpace(key), strings.TrimSpace(raw)
+		if section != "" {
+			key = section + "." + key
+		}
+		if first, dup := seen[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is already set on line %d", line, key, first)
+		}
+		seen[key] = line
+
+		value, err := tomlScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", line, key, err)
+		}
+		values = append(values, tomlValue{key: key, value: value, line: line})
+	}
+
+	return values, scanner.Err()
+}
+
+// stripComment cuts a # comment off a line, leaving any # inside a quoted string alone.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+func tomlScalar(raw string) (string, error) {
+	switch {
+	case raw == "":
+		return "", errors.New("missing value")
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") || strings.Contains(raw[1:len(raw)-1], "'") {
+			return "", errors.New("invalid literal string")
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw, nil
+	}
+
+	if _, err := strconv.ParseInt(strings.ReplaceAll(raw, "_", ""), 10, 64); err == nil {
+		return strings.ReplaceAll(raw, "_", ""), nil
+	}
+
+	return "", fmt.Errorf("unsupported value %s, quote strings", raw)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTOML(t *testing.T) {
+	input := `# vector-db settings
+collection = "plays" # the default collection
+
+[qdrant]
+host = 'qdrant.internal'
+port = 6_334
+api_key = "se#cret \"quoted\""   # a # inside quotes is not a comment
+tls = true
+ca_cert = '/etc/ssl/#1.pem'
+`
+	values, err := parseTOML(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []tomlValue{
+		{key: "collection", value: "plays", line: 2},
+		{key: "qdrant.host", value: "qdrant.internal", line: 5},
+		{key: "qdrant.port", value: "6334", line: 6},
+		{key: "qdrant.api_key", value: `se#cret "quoted"`, line: 7},
+		{key: "qdrant.tls", value: "true", line: 8},
+		{key: "qdrant.ca_cert", value: "/etc/ssl/#1.pem", line: 9},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %+v, want %+v", values, want)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"collection", "line 1: want key = value"},
+		{"\n\n[qdrant\nhost = 'x'", "line 3: invalid section header"},
+		{"[[qdrant]]", "line 1: invalid section header"},
+		{"[qdrant]\nport =", "line 2: qdrant.port: missing value"},
+		{"[qdrant]\nhost = localhost", "line 2: qdrant.host: unsupported value localhost"},
+		{"[qdrant]\nhost = 'it's'", "line 2: qdrant.host: invalid literal string"},
+		{"[qdrant]\nhost = \"open", "line 2: qdrant.host:"},
+		{"[qdrant]\nport = 1.5", "line 2: qdrant.port: unsupported value"},
+		{"[qdrant]\nhost = 'a'\n\nhost = 'b'", "line 4: qdrant.host is already set on line 2"},
+	}
+	for _, tt := range tests {
+		_, err := parseTOML(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseTOML(%q): got %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+// clearConfigEnv unsets every configuration variable for the length of the test.
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+
+	for _, env := range []string{"VECTOR_DB_CONFIG", "VECTOR_DB_COLLECTION", "QDRANT_HOST", "QDRANT_PORT", "QDRANT_API_KEY",
+		"QDRANT_TLS", "QDRANT_CA_CERT", "QDRANT_TLS_SKIP_VERIFY", "QDRANT_TIMEOUT"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "vector-db.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// loadTestConfig loads path with the given environment and command line flags.
+func loadTestConfig(t *testing.T, path string, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+
+	clearConfigEnv(t)
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	fs := flag.NewFlagSet("vector-db", flag.ContinueOnError)
+	values := registerConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return loadConfig(path, fs, values)
+}
+
+// TestLoadConfigPrecedence sets each setting in every layer up to one, so the layer that wins shows which
+// one overrides which: defaults, then the file, then the environment, then flags.
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+collection = "file"
+[qdrant]
+host = "file-host"
+port = 1001
+timeout = "5s"
+`)
+	env := map[string]string{
+		"QDRANT_HOST": "env-host",
+		"QDRANT_PORT": "2002",
+	}
+	c, err := loadTestConfig(t, path, env, "-qdrant-port", "3003")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, value, source string
+	}{
+		{"qdrant.tls", "false", "default"},
+		{"collection", "file", "file " + path},
+		{"qdrant.timeout", "5s", "file " + path},
+		{"qdrant.host", "env-host", "env QDRANT_HOST"},
+		{"qdrant.port", "3003", "flag -qdrant-port"},
+	}
+	for _, tt := range tests {
+		for _, s := range configSettings {
+			if s.key == tt.key && (s.get(c) != tt.value || c.sources[s.key] != tt.source) {
+				t.Errorf("%s = %s from %s, want %s from %s", s.key, s.get(c), c.sources[s.key], tt.value, tt.source)
+			}
+		}
+	}
+
+	// Without a path argument the file named by VECTOR_DB_CONFIG is read.
+	c, err = loadTestConfig(t, "", map[string]string{"VECTOR_DB_CONFIG": path})
+	if err != nil || c.Collection != "file" || c.Port != 1001 {
+		t.Errorf("VECTOR_DB_CONFIG: got %+v, %v", c, err)
+	}
+
+	c, err = loadTestConfig(t, "", nil)
+	if err != nil || c.Collection != CollectionName || c.Host != "localhost" || c.Port != 6334 || c.Timeout != 30*time.Second {
+		t.Errorf("defaults: got %+v, %v", c, err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown setting", "[qdrant]\n\nhots = 'x'", nil, nil, ".toml:3: unknown setting qdrant.hots"},
+		{"bad value in the file", "[qdrant]\nport = 'many'", nil, nil, `.toml:2: invalid qdrant.port "many"`},
+		{"bad TOML", "[qdrant]\nport = many", nil, nil, ".toml: line 2: qdrant.port: unsupported value many"},
+		{"bad environment variable", "", map[string]string{"QDRANT_TLS": "yes please"}, nil, `invalid QDRANT_TLS "yes please"`},
+		{"bad flag", "", nil, []string{"-qdrant-timeout", "soon"}, `invalid -qdrant-timeout "soon"`},
+		{"empty collection", "", nil, []string{"-collection", ""}, "collection is empty"},
+		{"empty host", "", map[string]string{"QDRANT_HOST": ""}, nil, "qdrant.host is empty"},
+		{"port out of range", "[qdrant]\nport = 70000", nil, nil, "qdrant.port 70000 is not between 1 and 65535"},
+		{"negative timeout", "", nil, []string{"-qdrant-timeout", "-1s"}, "qdrant.timeout -1s is negative"},
+		{"TLS options without TLS", "[qdrant]\ntls_skip_verify = true", nil, nil, "need qdrant.tls"},
+		{"missing CA certificate", "[qdrant]\ntls = true\nca_cert = '/no/such/ca.pem'", nil, nil, "qdrant.ca_cert:"},
+	}
+	for _, tt := range tests {
+		path := writeConfigFile(t, tt.file)
+		_, err := loadTestConfig(t, path, tt.env, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := loadTestConfig(t, filepath.Join(t.TempDir(), "missing.toml"), nil); err == nil {
+		t.Error("a missing config file was ignored")
+	}
+
+	// Every problem is reported at once.
+	_, err := loadTestConfig(t, "", map[string]string{"QDRANT_HOST": "", "QDRANT_PORT": "0"})
+	if err == nil || !strings.Contains(err.Error(), "qdrant.host is empty") || !strings.Contains(err.Error(), "qdrant.port 0") {
+		t.Errorf("got %v, want both the host and the port reported", err)
+	}
+}
+
+func TestConfigShow(t *testing.T) {
+	c, err := loadTestConfig(t, "", map[string]string{"QDRANT_API_KEY": "s3cret-key"}, "-qdrant-host", "qdrant.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	c.show(&out)
+	shown := out.String()
+
+	if strings.Contains(shown, "s3cret-key") {
+		t.Errorf("show printed the API key:\n%s", shown)
+	}
+	for _, want := range []string{
+		`api_key          = "<redacted>"`,
+		`host             = "qdrant.internal"`,
+		"# flag -qdrant-host",
+		"# env QDRANT_API_KEY",
+		"\n[qdrant]\n",
+	} {
+		if !strings.Contains(shown, want) {
+			t.Errorf("show is missing %q:\n%s", want, shown)
+		}
+	}
+
+	// The output reads back as a config file with the same values, apart from the redacted key.
+	values, err := parseTOML(strings.NewReader(shown))
+	if err != nil {
+		t.Fatalf("show output is not a valid config file: %v\n%s", err, shown)
+	}
+	for _, v := range values {
+		for _, s := range configSettings {
+			if s.key == v.key && !s.secret && s.get(c) != v.value {
+				t.Errorf("%s reads back as %q, want %q", v.key, v.value, s.get(c))
+			}
+		}
+	}
+
+	// An unset key shows as empty rather than redacted.
+	c, err = loadTestConfig(t, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	c.show(&out)
+	if !strings.Contains(out.String(), `api_key          = ""`) {
+		t.Errorf("an unset API key shows as\n%s", out.String())
+	}
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// diskStore is a VectorStore that keeps its data in a directory so it survives restarts without Qdrant.
+// Everything is held and searched in memory by a memoryStore. Every change is first appended to a write-ahead
+// log, and once the log grows long enough the whole state is written out as a snapshot and the log starts over.
+// On startup the snapshot is loaded and the log replayed on top of it.
+//
+// Both files are a sequence of records: a little endian uint32 length, a CRC-32C of the body and a JSON body.
+// A record that is cut short or fails its checksum marks where a crash interrupted an append, the log is
+// truncated there. Every record carries a sequence number and a snapshot remembers the last one it includes,
+// so a crash between writing a snapshot and emptying the log can't apply anything twice.
+
+const (
+	SyncAlways   = "always"
+	SyncInterval = "interval"
+	SyncNever    = "never"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.db"
+)
+
+type DiskOptions struct {
+	// Sync is when the log is flushed to stable storage. SyncAlways fsyncs before every write returns,
+	// SyncInterval fsyncs every SyncInterval so a power cut can lose that much, and SyncNever leaves it to
+	// the operating system. A process crash loses nothing under any of them.
+	Sync         string
+	SyncInterval time.Duration
+	// SnapshotEvery is the number of log records that triggers a snapshot, 0 only snapshots on Close.
+	SnapshotEvery int
+}
+
+func defaultDiskOptions() DiskOptions {
+	return DiskOptions{
+		Sync:          SyncAlways,
+		SyncInterval:  time.Second,
+		SnapshotEvery: 1000,
+	}
+}
+
+const (
+	opSnapshot         = "snapshot"
+	opCreateCollection = "create_collection"
+	opUpsert           = "upsert"
+	opDelete           = "delete"
+	opDropCollection   = "drop_collection"
+)
+
+type walRecord struct {
+	Seq        uint64            `json:"seq"`
+	Op         string            `json:"op"`
+	Collection string            `json:"collection,omitempty"`
+	Config     *CollectionConfig `json:"config,omitempty"`
+	Points     []Point           `json:"points,omitempty"`
+	IDs        []string          `json:"ids,omitempty"`
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptRecord = errors.New("corrupt record")
+
+// maxRecordLen bounds a record body. Longer records are refused when written, and a longer length read back can
+// only be damage, which must not turn into a multi-gigabyte allocation.
+const maxRecordLen = 256 << 20
+
+type diskStore struct {
+	*memoryStore
+
+	dir  string
+	opts DiskOptions
+
+	// mu orders writes so the log and the in-memory state see changes in the same order.
+	mu         sync.Mutex
+	wal        *os.File
+	seq        uint64
+	walRecords int
+	// failed is set when an append could not be undone, the log may hold a record the store never applied, so
+	// every later write fails with it rather than append after the damage.
+	failed error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newDiskStore opens the store in dir, creating it if needed, and recovers whatever state it holds.
+func newDiskStore(dir string, opts DiskOptions) (*diskStore, error) {
+	switch opts.Sync {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if opts.SyncInterval <= 0 {
+			return nil, fmt.Errorf("sync interval must be positive, got %s", opts.SyncInterval)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q (want %s, %s or %s)", opts.Sync, SyncAlways, SyncInterval, SyncNever)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &diskStore{
+		memoryStore: newMemoryStore(),
+		dir:         dir,
+		opts:        opts,
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.openWAL(); err != nil {
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.syncLoop()
+	}
+
+	return s, nil
+}
+
+func (s *diskStore) CreateCollection(_ context.Context, name string, config CollectionConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hasCollection(name) {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	return s.write(walRecord{Op: opCreateCollection, Collection: name, Config: &config})
+}
+
+func (s *diskStore) DropCollection(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasCollection(name) {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+
+	return s.write(walRecord{Op: opDropCollection, Collection: name})
+}
+
+func (s *diskStore) Upsert(_ context.Context, collection string, points []Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUpsert(collection, points); err != nil {
+		return err
+	}
+
+	return s.write(walRecord{Op: opUpsert, Collection: collection, Points: points})
+}
+
+func (s *diskStore) Delete(_ context.Context, collection string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hasCollection(collection) {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, collection)
+	}
+
+	return s.write(walRecord{Op: opDelete, Collection: collection, IDs: ids})
+}
+
+// Close snapshots anything still only in the log so the next start has nothing to replay.
+func (s *diskStore) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.walRecords > 0 {
+		err = s.snapshot()
+	}
+	if syncErr := s.wal.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := s.wal.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// write appends rec to the log and then applies it, callers must hold s.mu and have checked that it applies.
+func (s *diskStore) write(rec walRecord) error {
+	if s.failed != nil {
+		return s.failed
+	}
+
+	rec.Seq = s.seq + 1
+	offset, err := s.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to find the end of the write-ahead log: %w", err)
+	}
+	if err := writeRecord(s.wal, rec); err != nil {
+		err = fmt.Errorf("failed to append to write-ahead log: %w", err)
+		// A torn record left in the middle of the log would make recovery drop every record after it.
+		s.rewind(offset, err)
+		return err
+	}
+	if s.opts.Sync == SyncAlways {
+		if err := s.wal.Sync(); err != nil {
+			// After a failed fsync nothing says what reached the disk, so the log can't be trusted any more.
+			s.failed = fmt.Errorf("write-ahead log unusable after failed sync: %w", err)
+			return s.failed
+		}
+	}
+	s.seq = rec.Seq
+	s.walRecords++
+
+	if err := s.apply(rec); err != nil {
+		return err
+	}
+
+	if s.opts.SnapshotEvery > 0 && s.walRecords >= s.opts.SnapshotEvery {
+		if err := s.snapshot(); err != nil {
+			// The log still has everything, so this only costs a longer replay next time.
+			log.Printf("warning: snapshot failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// rewind cuts the log back to offset after a failed append, or marks the store failed if it can't.
+func (s *diskStore) rewind(offset int64, cause error) {
+	if err := s.wal.Truncate(offset); err != nil {
+		s.failed = fmt.Errorf("write-ahead log unusable after %v: %w", cause, err)
+		return
+	}
+	if _, err := s.wal.Seek(offset, io.SeekStart); err != nil {
+		s.failed = fmt.Errorf("write-ahead log unusable after %v: %w", cause, err)
+	}
+}
+
+func (s *diskStore) apply(rec walRecord) error {
+	ctx := context.Background()
+	switch rec.Op {
+	case opCreateCollection:
+		if rec.Config == nil {
+			return fmt.Errorf("record %d creates collection %s without a config", rec.Seq, rec.Collection)
+		}
+		return s.memoryStore.CreateCollection(ctx, rec.Collection, *rec.Config)
+	case opUpsert:
+		return s.memoryStore.Upsert(ctx, rec.Collection, rec.Points)
+	case opDelete:
+		return s.memoryStore.Delete(ctx, rec.Collection, rec.IDs)
+	case opDropCollection:
+		return s.memoryStore.DropCollection(ctx, rec.Collection)
+	default:
+		return fmt.Errorf("record %d has unknown op %q", rec.Seq, rec.Op)
+	}
+}
+
+func (s *diskStore) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Snapshots are renamed into place complete, so unlike the log any damage here is a real error.
+	r := bufio.NewReader(f)
+	header, err := readRecord(r)
+	if err != nil || header.Op != opSnapshot {
+		return fmt.Errorf("snapshot %s has no valid header", f.Name())
+	}
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %w", f.Name(), err)
+		}
+		if err := s.apply(rec); err != nil {
+			return fmt.Errorf("snapshot %s: %w", f.Name(), err)
+		}
+	}
+	s.seq = header.Seq
+
+	return nil
+}
+
+// openWAL replays the log past the snapshot and leaves it open for appending after the last good record.
+func (s *diskStore) openWAL() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	r := &countingReader{r: bufio.NewReader(f)}
+	var good int64
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("warning: %s: dropping everything after byte %d: %v", f.Name(), good, err)
+			break
+		}
+		good = r.n
+		if rec.Seq <= s.seq {
+			// Already part of the snapshot, the log was not emptied before a crash.
+			continue
+		}
+		if err := s.apply(rec); err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		s.seq = rec.Seq
+		s.walRecords++
+	}
+
+	if err := f.Truncate(good); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	s.wal = f
+
+	return syncDir(s.dir)
+}
+
+// snapshot writes every collection to a new snapshot file, renames it over the old one and empties the log.
+// Callers must hold s.mu.
+func (s *diskStore) snapshot() error {
+	path := filepath.Join(s.dir, snapshotFileName)
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	err = writeRecord(w, walRecord{Seq: s.seq, Op: opSnapshot})
+	if err == nil {
+		err = s.writeCollections(w)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	if err := s.wal.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.wal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.walRecords = 0
+
+	return s.wal.Sync()
+}
+
+// writeCollections writes the in-memory state as create and upsert records, in a stable order.
+func (s *diskStore) writeCollections(w io.Writer) error {
+	s.memoryStore.mu.RLock()
+	defer s.memoryStore.mu.RUnlock()
+
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const batch = 256
+	for _, name := range names {
+		c := s.collections[name]
+		config := c.config
+		if err := writeRecord(w, walRecord{Op: opCreateCollection, Collection: name, Config: &config}); err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(c.points))
+		for id := range c.points {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for start := 0; start < len(ids); start += batch {
+			points := make([]Point, 0, batch)
+			for _, id := range ids[start:min(start+batch, len(ids))] {
+				points = append(points, c.points[id])
+			}
+			if err := writeRecord(w, walRecord{Op: opUpsert, Collection: name, Points: points}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *diskStore) syncLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.wal.Sync(); err != nil {
+				log.Printf("warning: failed to sync write-ahead log: %v", err)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func writeRecord(w io.Writer, rec walRecord) error {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if len(body) > maxRecordLen {
+		return fmt.Errorf("record of %d bytes is over the limit of %d, write fewer points at a time", len(body), maxRecordLen)
+	}
+
+	buf := make([]byte, 8, 8+len(body))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(body, crcTable))
+	_, err = w.Write(append(buf, body...))
+
+	return err
+}
+
+// readRecord returns io.EOF at a clean end and errCorruptRecord for a torn or damaged record.
+func readRecord(r io.Reader) (walRecord, error) {
+	var rec walRecord
+
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return rec, io.EOF
+		}
+		return rec, fmt.Errorf("%w: short header", errCorruptRecord)
+	}
+
+	n := binary.LittleEndian.Uint32(header[0:4])
+	if n > maxRecordLen {
+		return rec, fmt.Errorf("%w: length %d is over the limit", errCorruptRecord, n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return rec, fmt.Errorf("%w: short body", errCorruptRecord)
+	}
+	if crc32.Checksum(body, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return rec, fmt.Errorf("%w: checksum mismatch", errCorruptRecord)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&rec); err != nil {
+		return rec, fmt.Errorf("%w: %v", errCorruptRecord, err)
+	}
+	for i := range rec.Points {
+		rec.Points[i].Payload = normalizePayload(rec.Points[i].Payload)
+	}
+
+	return rec, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// syncDir makes a rename or a newly created file in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func openTestDiskStore(t *testing.T, dir string) *diskStore {
+	t.Helper()
+
+	opts := defaultDiskOptions()
+	opts.SnapshotEvery = 0
+	s, err := newDiskStore(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// crash closes the log without the snapshot Close would write, the way a killed process leaves it.
+func crash(t *testing.T, s *diskStore) {
+	t.Helper()
+
+	if err := s.wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dump returns every point of every collection of s, by collection name.
+func dump(t *testing.T, s *diskStore) map[string][]Point {
+	t.Helper()
+
+	ctx := context.Background()
+	names, err := s.Collections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := make(map[string][]Point, len(names))
+	for _, name := range names {
+		if state[name], err = s.Scroll(ctx, name, nil, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return state
+}
+
+// writeHistory creates, fills, edits and drops collections, one log record per call.
+func writeHistory(t *testing.T, s *diskStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	steps := []func() error{
+		func() error {
+			return s.CreateCollection(ctx, "plays", CollectionConfig{VectorSize: 2, Distance: DistanceDot})
+		},
+		func() error { return s.CreateCollection(ctx, "scratch", CollectionConfig{VectorSize: 3}) },
+		func() error {
+			return s.Upsert(ctx, "plays", []Point{
+				{ID: "1", Vector: []float32{1, 0}, Payload: map[string]any{"title": "Macbeth", "act": int64(1)}},
+				{ID: "2", Vector: []float32{0, 1}, Payload: map[string]any{"title": "Hamlet", "score": 0.5}},
+				{ID: "3", Vector: []float32{1, 1}},
+			})
+		},
+		func() error { return s.Delete(ctx, "plays", []string{"3"}) },
+		func() error {
+			return s.Upsert(ctx, "plays", []Point{{ID: "2", Vector: []float32{0, 2}, Payload: map[string]any{"tags": []any{"ghost", int64(5)}}}})
+		},
+		func() error { return s.DropCollection(ctx, "scratch") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+}
+
+// TestDiskStoreReopen checks that the same writes come back after a restart however they were stored: only in
+// the log, in a snapshot with the rest of the log on top, or in a snapshot written on Close.
+func TestDiskStoreReopen(t *testing.T) {
+	tests := []struct {
+		snapshotEvery int
+		closed        bool
+		// wantLog is the number of records replayed from the log on top of the snapshot.
+		wantLog int
+	}{
+		{0, false, 6},
+		{0, true, 0},
+		{1, false, 0},
+		{4, false, 2},
+		{4, true, 0},
+		{100, false, 6},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("snapshot every %d, closed %t", tt.snapshotEvery, tt.closed), func(t *testing.T) {
+			dir := t.TempDir()
+			opts := defaultDiskOptions()
+			opts.SnapshotEvery = tt.snapshotEvery
+
+			s, err := newDiskStore(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeHistory(t, s)
+			want := dump(t, s)
+			if tt.closed {
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				crash(t, s)
+			}
+
+			s, err = newDiskStore(dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if got := dump(t, s); !reflect.DeepEqual(got, want) {
+				t.Errorf("after reopening got\n%v\nwant\n%v", got, want)
+			}
+			if s.seq != 6 || s.walRecords != tt.wantLog {
+				t.Errorf("reopened at sequence %d with %d records from the log, want 6 and %d", s.seq, s.walRecords, tt.wantLog)
+			}
+		})
+	}
+}
+
+// TestDiskStoreSkipsSnapshottedRecords puts back the log a crash left behind after a snapshot was renamed into
+// place but before the log was emptied. Replaying those records again would create collections twice.
+func TestDiskStoreSkipsSnapshottedRecords(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, walFileName)
+
+	s := openTestDiskStore(t, dir)
+	writeHistory(t, s)
+	want := dump(t, s)
+	stale, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	if err := s.snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Unlock()
+	crash(t, s)
+
+	// The stale log goes back, followed by one record the snapshot doesn't have.
+	var next bytes.Buffer
+	if err := writeRecord(&next, walRecord{Seq: 7, Op: opDelete, Collection: "plays", IDs: []string{"1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(walPath, append(stale, next.Bytes()...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestDiskStore(t, dir)
+	defer s.Close()
+	got, err := s.Scroll(ctx, "plays", nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want["plays"][1:]) {
+		t.Errorf("got %v, want %v with point 1 deleted", got, want["plays"][1:])
+	}
+	if s.seq != 7 || s.walRecords != 1 {
+		t.Errorf("reopened at sequence %d with %d records to snapshot, want 7 and 1", s.seq, s.walRecords)
+	}
+}
+
+// TestDiskStoreTornTail cuts the last record of the log short, as a crash in the middle of an append would.
+func TestDiskStoreTornTail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, walFileName)
+
+	s := openTestDiskStore(t, dir)
+	if err := s.CreateCollection(ctx, "c", CollectionConfig{VectorSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Upsert(ctx, "c", []Point{{ID: "1", Vector: []float32{1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := info.Size()
+	if err := s.Upsert(ctx, "c", []Point{{ID: "2", Vector: []float32{0, 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+	if err := os.Truncate(walPath, good+12); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestDiskStore(t, dir)
+	if info, err := os.Stat(walPath); err != nil || info.Size() != good {
+		t.Errorf("log is %d bytes after reopening, want the torn record cut off at %d", info.Size(), good)
+	}
+	if points, err := s.Scroll(ctx, "c", nil, false); err != nil || len(points) != 1 || points[0].ID != "1" {
+		t.Errorf("got %v, %v, want only point 1", points, err)
+	}
+
+	// Writes after the recovery append to the last whole record and survive another restart.
+	if err := s.Upsert(ctx, "c", []Point{{ID: "3", Vector: []float32{1, 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+	s = openTestDiskStore(t, dir)
+	defer s.Close()
+	if n, err := s.Count(ctx, "c", nil); err != nil || n != 2 {
+		t.Errorf("got %d points, %v after the second restart, want 2", n, err)
+	}
+	if s.seq != 3 {
+		t.Errorf("reopened at sequence %d, want 3", s.seq)
+	}
+}
+
+// TestDiskStoreRewindsFailedAppend leaves half a record at the end of the log the way a failed write would,
+// and checks that the writes acknowledged after it survive a restart.
+func TestDiskStoreRewindsFailedAppend(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := openTestDiskStore(t, dir)
+	if err := s.CreateCollection(ctx, "c", CollectionConfig{VectorSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Upsert(ctx, "c", []Point{{ID: "1", Vector: []float32{1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	offset, err := s.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.wal.Write([]byte{0xff, 0x00, 0x00, 0x00, 1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	s.rewind(offset, errors.New("disk full"))
+	if s.failed != nil {
+		t.Fatalf("rewind failed: %v", s.failed)
+	}
+
+	if err := s.Upsert(ctx, "c", []Point{{ID: "2", Vector: []float32{0, 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	// Skip Close, which would snapshot and hide what replaying the log does.
+	if err := s.wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestDiskStore(t, dir)
+	defer s.Close()
+	n, err := s.Count(ctx, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d points after replaying the log, want 2", n)
+	}
+}
+
+func TestReadRecordRejectsHugeLength(t *testing.T) {
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:4], 0xffffffff)
+
+	_, err := readRecord(bytes.NewReader(header[:]))
+	if !errors.Is(err, errCorruptRecord) {
+		t.Errorf("got %v, want %v", err, errCorruptRecord)
+	}
+}
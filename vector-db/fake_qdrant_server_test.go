@@ -0,0 +1,469 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeQdrant serves the part of Qdrant's gRPC API that qdrantStore uses, backed by a memoryStore, so the real
+// qdrant.Client can be driven end to end in go test without Docker. Collections hold a single unnamed dense vector and
+// filters support match, range, is empty and nested filters, as qdrantFilter produces them. Everything else
+// answers Unimplemented.
+type fakeQdrant struct {
+	store    *memoryStore
+	server   *grpc.Server
+	listener net.Listener
+}
+
+// fakeQdrantVersion is what the fake reports in health checks, the client warns unless it is close to its own.
+const fakeQdrantVersion = "1.16.0"
+
+// startFakeQdrant listens on addr, e.g. "localhost:0" for any free port, and serves until Stop.
+func startFakeQdrant(addr string) (*fakeQdrant, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fakeQdrant{
+		store:    newMemoryStore(),
+		server:   grpc.NewServer(),
+		listener: listener,
+	}
+	qdrant.RegisterQdrantServer(f.server, fakeHealth{})
+	qdrant.RegisterCollectionsServer(f.server, &fakeCollections{store: f.store})
+	qdrant.RegisterPointsServer(f.server, &fakePoints{store: f.store})
+	go f.server.Serve(listener)
+
+	return f, nil
+}
+
+// Config returns a client config that connects to the fake.
+func (f *fakeQdrant) Config() *qdrant.Config {
+	addr := f.listener.Addr().(*net.TCPAddr)
+
+	return &qdrant.Config{Host: addr.IP.String(), Port: addr.Port}
+}
+
+func (f *fakeQdrant) Stop() { f.server.Stop() }
+
+type fakeHealth struct {
+	qdrant.UnimplementedQdrantServer
+}
+
+func (fakeHealth) HealthCheck(context.Context, *qdrant.HealthCheckRequest) (*qdrant.HealthCheckReply, error) {
+	return &qdrant.HealthCheckReply{Title: "vector-db fake qdrant", Version: fakeQdrantVersion}, nil
+}
+
+type fakeCollections struct {
+	qdrant.UnimplementedCollectionsServer
+	store *memoryStore
+}
+
+func (s *fakeCollections) Create(ctx context.Context, req *qdrant.CreateCollection) (*qdrant.CollectionOperationResponse, error) {
+	params := req.GetVectorsConfig().GetParams()
+	if params == nil {
+		return nil, status.Error(codes.InvalidArgument, "the fake only supports a single unnamed vector")
+	}
+	switch d := params.GetDistance(); d {
+	case qdrant.Distance_Cosine, qdrant.Distance_Dot, qdrant.Distance_Euclid:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "the fake does not support %s distance", d)
+	}
+
+	err := s.store.CreateCollection(ctx, req.GetCollectionName(), CollectionConfig{
+		VectorSize: params.GetSize(),
+		Distance:   distanceFromQdrant(params.GetDistance()),
+	})
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+
+	return &qdrant.CollectionOperationResponse{Result: true}, nil
+}
+
+func (s *fakeCollections) Get(ctx context.Context, req *qdrant.GetCollectionInfoRequest) (*qdrant.GetCollectionInfoResponse, error) {
+	config, err := s.store.Collection(ctx, req.GetCollectionName())
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+	count, err := s.store.Count(ctx, req.GetCollectionName(), nil)
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+
+	return &qdrant.GetCollectionInfoResponse{Result: &qdrant.CollectionInfo{
+		Status:      qdrant.CollectionStatus_Green,
+		PointsCount: qdrant.PtrOf(count),
+		Config: &qdrant.CollectionConfig{
+			Params: &qdrant.CollectionParams{
+				ShardNumber: 1,
+				VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+					Size:     config.VectorSize,
+					Distance: qdrantDistance(config.Distance),
+				}),
+			},
+		},
+	}}, nil
+}
+
+func (s *fakeCollections) List(ctx context.Context, _ *qdrant.ListCollectionsRequest) (*qdrant.ListCollectionsResponse, error) {
+	names, err := s.store.Collections(ctx)
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+
+	resp := &qdrant.ListCollectionsResponse{}
+	for _, name := range names {
+		resp.Collections = append(resp.Collections, &qdrant.CollectionDescription{Name: name})
+	}
+
+	return resp, nil
+}
+
+func (s *fakeCollections) Delete(ctx context.Context, req *qdrant.DeleteCollection) (*qdrant.CollectionOperationResponse, error) {
+	// Like Qdrant, dropping a collection that doesn't exist is not an error, the result just says nothing happened.
+	err := s.store.DropCollection(ctx, req.GetCollectionName())
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return nil, fakeStatus(err)
+	}
+
+	return &qdrant.CollectionOperationResponse{Result: err == nil}, nil
+}
+
+func (s *fakeCollections) CollectionExists(_ context.Context, req *qdrant.CollectionExistsRequest) (*qdrant.CollectionExistsResponse, error) {
+	return &qdrant.CollectionExistsResponse{Result: &qdrant.CollectionExists{
+		Exists: s.store.hasCollection(req.GetCollectionName()),
+	}}, nil
+}
+
+type fakePoints struct {
+	qdrant.UnimplementedPointsServer
+	store       *memoryStore
+	operationID atomic.Uint64
+}
+
+func (s *fakePoints) Upsert(ctx context.Context, req *qdrant.UpsertPoints) (*qdrant.PointsOperationResponse, error) {
+	points := make([]Point, 0, len(req.GetPoints()))
+	for _, p := range req.GetPoints() {
+		v := p.GetVectors().GetVector()
+		if v == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "point %s: the fake only supports a single unnamed vector", pointIDString(p.GetId()))
+		}
+		data := v.GetDense().GetData()
+		if data == nil {
+			data = v.GetData()
+		}
+		points = append(points, Point{
+			ID:      pointIDString(p.GetId()),
+			Vector:  data,
+			Payload: payloadToMap(p.GetPayload()),
+		})
+	}
+
+	if err := s.store.Upsert(ctx, req.GetCollectionName(), points); err != nil {
+		return nil, fakeStatus(err)
+	}
+
+	return s.completed(), nil
+}
+
+func (s *fakePoints) Delete(ctx context.Context, req *qdrant.DeletePoints) (*qdrant.PointsOperationResponse, error) {
+	var ids []string
+	switch selector := req.GetPoints().GetPointsSelectorOneOf().(type) {
+	case *qdrant.PointsSelector_Points:
+		for _, id := range selector.Points.GetIds() {
+			ids = append(ids, pointIDString(id))
+		}
+	case *qdrant.PointsSelector_Filter:
+		filter, err := filterFromQdrant(selector.Filter)
+		if err != nil {
+			return nil, err
+		}
+		points, err := s.store.Scroll(ctx, req.GetCollectionName(), filter, false)
+		if err != nil {
+			return nil, fakeStatus(err)
+		}
+		for _, p := range points {
+			ids = append(ids, p.ID)
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "no points selected")
+	}
+
+	if err := s.store.Delete(ctx, req.GetCollectionName(), ids); err != nil {
+		return nil, fakeStatus(err)
+	}
+
+	return s.completed(), nil
+}
+
+func (s *fakePoints) Get(ctx context.Context, req *qdrant.GetPoints) (*qdrant.GetResponse, error) {
+	ids := make([]string, 0, len(req.GetIds()))
+	for _, id := range req.GetIds() {
+		ids = append(ids, pointIDString(id))
+	}
+	points, err := s.store.Get(ctx, req.GetCollectionName(), ids)
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+
+	resp := &qdrant.GetResponse{}
+	for _, p := range points {
+		rp, err := retrievedPoint(p, req.GetWithPayload().GetEnable(), req.GetWithVectors().GetEnable())
+		if err != nil {
+			return nil, err
+		}
+		resp.Result = append(resp.Result, rp)
+	}
+
+	return resp, nil
+}
+
+func (s *fakePoints) Search(ctx context.Context, req *qdrant.SearchPoints) (*qdrant.SearchResponse, error) {
+	filter, err := filterFromQdrant(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	offset := req.GetOffset()
+	results, err := s.store.Search(ctx, req.GetCollectionName(), SearchQuery{
+		Vector:         req.GetVector(),
+		Limit:          req.GetLimit() + offset,
+		Filter:         filter,
+		ScoreThreshold: req.ScoreThreshold,
+	})
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+	results = results[min(offset, uint64(len(results))):]
+
+	var withVectors map[string][]float32
+	if req.GetWithVectors().GetEnable() {
+		ids := make([]string, 0, len(results))
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		points, err := s.store.Get(ctx, req.GetCollectionName(), ids)
+		if err != nil {
+			return nil, fakeStatus(err)
+		}
+		withVectors = make(map[string][]float32, len(points))
+		for _, p := range points {
+			withVectors[p.ID] = p.Vector
+		}
+	}
+
+	resp := &qdrant.SearchResponse{}
+	for _, r := range results {
+		rp, err := retrievedPoint(Point{ID: r.ID, Vector: withVectors[r.ID], Payload: r.Payload},
+			req.GetWithPayload().GetEnable(), withVectors != nil)
+		if err != nil {
+			return nil, err
+		}
+		resp.Result = append(resp.Result, &qdrant.ScoredPoint{
+			Id:      rp.Id,
+			Payload: rp.Payload,
+			Vectors: rp.Vectors,
+			Score:   r.Score,
+		})
+	}
+
+	return resp, nil
+}
+
+// Scroll pages through points in Qdrant's order: numeric IDs by value, then UUIDs. The offset is the first ID
+// of the page.
+func (s *fakePoints) Scroll(ctx context.Context, req *qdrant.ScrollPoints) (*qdrant.ScrollResponse, error) {
+	filter, err := filterFromQdrant(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	points, err := s.store.Scroll(ctx, req.GetCollectionName(), filter, req.GetWithVectors().GetEnable())
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+	slices.SortFunc(points, func(a, b Point) int { return comparePointIDs(a.ID, b.ID) })
+
+	if req.Offset != nil {
+		offset := pointIDString(req.GetOffset())
+		for len(points) > 0 && comparePointIDs(points[0].ID, offset) < 0 {
+			points = points[1:]
+		}
+	}
+	limit := 10
+	if req.Limit != nil {
+		limit = int(req.GetLimit())
+	}
+
+	resp := &qdrant.ScrollResponse{}
+	if len(points) > limit {
+		resp.NextPageOffset = qdrantID(points[limit].ID)
+		points = points[:limit]
+	}
+	for _, p := range points {
+		rp, err := retrievedPoint(p, req.GetWithPayload().GetEnable(), req.GetWithVectors().GetEnable())
+		if err != nil {
+			return nil, err
+		}
+		resp.Result = append(resp.Result, rp)
+	}
+
+	return resp, nil
+}
+
+func (s *fakePoints) Count(ctx context.Context, req *qdrant.CountPoints) (*qdrant.CountResponse, error) {
+	filter, err := filterFromQdrant(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.store.Count(ctx, req.GetCollectionName(), filter)
+	if err != nil {
+		return nil, fakeStatus(err)
+	}
+
+	return &qdrant.CountResponse{Result: &qdrant.CountResult{Count: count}}, nil
+}
+
+// comparePointIDs orders IDs the way Qdrant does, numeric IDs before UUIDs, so "9" comes before "10".
+func comparePointIDs(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+}
+
+// completed answers a write the way Qdrant does when asked to wait for it.
+func (s *fakePoints) completed() *qdrant.PointsOperationResponse {
+	return &qdrant.PointsOperationResponse{Result: &qdrant.UpdateResult{
+		OperationId: qdrant.PtrOf(s.operationID.Add(1)),
+		Status:      qdrant.UpdateStatus_Completed,
+	}}
+}
+
+func retrievedPoint(p Point, withPayload, withVectors bool) (*qdrant.RetrievedPoint, error) {
+	rp := &qdrant.RetrievedPoint{Id: qdrantID(p.ID)}
+	if withPayload {
+		payload, err := qdrant.TryValueMap(p.Payload)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "point %s: %v", p.ID, err)
+		}
+		rp.Payload = payload
+	}
+	if withVectors {
+		rp.Vectors = &qdrant.VectorsOutput{VectorsOptions: &qdrant.VectorsOutput_Vector{
+			Vector: &qdrant.VectorOutput{Vector: &qdrant.VectorOutput_Dense{
+				Dense: &qdrant.DenseVector{Data: p.Vector},
+			}},
+		}}
+	}
+
+	return rp, nil
+}
+
+// fakeStatus gives store errors the status codes Qdrant uses for them.
+func fakeStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrCollectionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrCollectionExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+// filterFromQdrant is the inverse of qdrantFilter. "is empty" has no Condition of its own and becomes a
+// filter that must not find the key.
+func filterFromQdrant(f *qdrant.Filter) (*Filter, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if f.MinShould != nil {
+		return nil, status.Error(codes.Unimplemented, "the fake does not support min_should")
+	}
+
+	var filter Filter
+	var err error
+	if filter.Must, err = conditionsFromQdrant(f.GetMust()); err != nil {
+		return nil, err
+	}
+	if filter.Should, err = conditionsFromQdrant(f.GetShould()); err != nil {
+		return nil, err
+	}
+	if filter.MustNot, err = conditionsFromQdrant(f.GetMustNot()); err != nil {
+		return nil, err
+	}
+
+	return &filter, nil
+}
+
+func conditionsFromQdrant(conds []*qdrant.Condition) ([]Condition, error) {
+	result := make([]Condition, 0, len(conds))
+	for _, c := range conds {
+		cond, err := conditionFromQdrant(c)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, cond)
+	}
+
+	return result, nil
+}
+
+func conditionFromQdrant(c *qdrant.Condition) (Condition, error) {
+	switch kind := c.GetConditionOneOf().(type) {
+	case *qdrant.Condition_Filter:
+		filter, err := filterFromQdrant(kind.Filter)
+		if err != nil {
+			return Condition{}, err
+		}
+		return Condition{Filter: filter}, nil
+	case *qdrant.Condition_IsEmpty:
+		return Condition{Filter: &Filter{
+			MustNot: []Condition{{Key: kind.IsEmpty.GetKey(), Exists: true}},
+		}}, nil
+	case *qdrant.Condition_Field:
+		return fieldConditionFromQdrant(kind.Field)
+	default:
+		return Condition{}, status.Errorf(codes.Unimplemented, "the fake does not support condition %T", kind)
+	}
+}
+
+func fieldConditionFromQdrant(field *qdrant.FieldCondition) (Condition, error) {
+	key := field.GetKey()
+	if r := field.GetRange(); r != nil {
+		return Condition{Key: key, Range: &Range{GT: r.Gt, GTE: r.Gte, LT: r.Lt, LTE: r.Lte}}, nil
+	}
+
+	switch match := field.GetMatch().GetMatchValue().(type) {
+	case *qdrant.Match_Keyword:
+		return Condition{Key: key, Match: match.Keyword}, nil
+	case *qdrant.Match_Integer:
+		return Condition{Key: key, Match: match.Integer}, nil
+	case *qdrant.Match_Boolean:
+		return Condition{Key: key, Match: match.Boolean}, nil
+	case nil:
+		return Condition{}, status.Errorf(codes.Unimplemented, "the fake does not support the condition on %s", key)
+	default:
+		return Condition{}, status.Errorf(codes.Unimplemented, "the fake does not support %T on %s", match, key)
+	}
+}
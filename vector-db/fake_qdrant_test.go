@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+)
+
+// newFakeQdrantStore starts the fake on a free port and returns a qdrantStore whose real qdrant.Client talks
+// to it over gRPC.
+func newFakeQdrantStore(t *testing.T) *qdrantStore {
+	t.Helper()
+
+	fake, err := startFakeQdrant("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fake.Stop)
+
+	client, err := qdrant.NewClient(fake.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newQdrantStore(client)
+	t.Cleanup(func() { store.Close() })
+
+	return store
+}
+
+func mustParseFilter(t *testing.T, expr string) *Filter {
+	t.Helper()
+
+	f, err := parseFilter(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestFakeQdrantCollections(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeQdrantStore(t)
+
+	want := CollectionConfig{VectorSize: 3, Distance: DistanceDot}
+	if err := store.CreateCollection(ctx, "docs", want); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateCollection(ctx, "docs", want); !errors.Is(err, ErrCollectionExists) {
+		t.Errorf("creating docs twice: got %v, want %v", err, ErrCollectionExists)
+	}
+	if err := store.CreateCollection(ctx, "notes", CollectionConfig{VectorSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Collection(ctx, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Collection: got %+v, want %+v", got, want)
+	}
+	names, err := store.Collections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[docs notes]" {
+		t.Errorf("Collections: got %v, want [docs notes]", names)
+	}
+
+	if err := store.DropCollection(ctx, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Collection(ctx, "docs"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Collection after drop: got %v, want %v", err, ErrCollectionNotFound)
+	}
+	if err := store.DropCollection(ctx, "docs"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("second drop: got %v, want %v", err, ErrCollectionNotFound)
+	}
+}
+
+func TestFakeQdrantPoints(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeQdrantStore(t)
+
+	if err := store.CreateCollection(ctx, "docs", CollectionConfig{VectorSize: 2, Distance: DistanceCosine}); err != nil {
+		t.Fatal(err)
+	}
+	points := []Point{
+		{ID: "1", Vector: []float32{1, 0}, Payload: map[string]any{"genre": "food", "year": 1990}},
+		{ID: "2", Vector: []float32{0.9, 0.1}, Payload: map[string]any{"genre": "travel", "year": 2005}},
+		{ID: "3", Vector: []float32{0, 1}, Payload: map[string]any{"genre": "food", "year": 2020}},
+		{ID: "9cd65e0f-61d1-5df0-b1dc-8e7fe1c5192d", Vector: []float32{0.5, 0.5}, Payload: map[string]any{"genre": "art"}},
+	}
+	if err := store.Upsert(ctx, "docs", points); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Upsert(ctx, "docs", []Point{{ID: "4", Vector: []float32{1, 2, 3}}}); err == nil {
+		t.Error("upserting a 3 dimensional vector into a 2 dimensional collection succeeded")
+	}
+
+	ids := func(scored []ScoredPoint) string {
+		var s []string
+		for _, p := range scored {
+			s = append(s, p.ID)
+		}
+		return fmt.Sprint(s)
+	}
+	searches := []struct {
+		name      string
+		query     SearchQuery
+		wantIDs   string
+		wantError bool
+	}{
+		{"nearest", SearchQuery{Vector: []float32{1, 0}, Limit: 2}, "[1 2]", false},
+		{"filter", SearchQuery{Vector: []float32{1, 0}, Limit: 5, Filter: mustParseFilter(t, "genre=food")}, "[1 3]", false},
+		{"range", SearchQuery{Vector: []float32{1, 0}, Limit: 5, Filter: mustParseFilter(t, "year>=2000")}, "[2 3]", false},
+		{"must not", SearchQuery{Vector: []float32{0, 1}, Limit: 5, Filter: mustParseFilter(t, "genre!=food,year?")}, "[2]", false},
+		{"threshold", SearchQuery{Vector: []float32{1, 0}, Limit: 5, ScoreThreshold: qdrant.PtrOf(float32(0.9))}, "[1 2]", false},
+		{"missing collection", SearchQuery{Vector: []float32{1, 0}, Limit: 1}, "", true},
+	}
+	for _, s := range searches {
+		collection := "docs"
+		if s.wantError {
+			collection = "missing"
+		}
+		got, err := store.Search(ctx, collection, s.query)
+		if s.wantError {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", s.name, ids(got))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", s.name, err)
+			continue
+		}
+		if ids(got) != s.wantIDs {
+			t.Errorf("%s: got %s, want %s", s.name, ids(got), s.wantIDs)
+		}
+	}
+
+	got, err := store.Get(ctx, "docs", []string{"3", "9cd65e0f-61d1-5df0-b1dc-8e7fe1c5192d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || fmt.Sprint(got[0].Vector) != "[0 1]" || got[0].Payload["year"] != int64(2020) || got[1].Payload["genre"] != "art" {
+		t.Errorf("Get: got %+v", got)
+	}
+
+	n, err := store.Count(ctx, "docs", mustParseFilter(t, "genre=food"))
+	if err != nil || n != 2 {
+		t.Errorf("Count of food: got %d, %v, want 2", n, err)
+	}
+	if err := store.Delete(ctx, "docs", []string{"1", "9cd65e0f-61d1-5df0-b1dc-8e7fe1c5192d"}); err != nil {
+		t.Fatal(err)
+	}
+	n, err = store.Count(ctx, "docs", nil)
+	if err != nil || n != 2 {
+		t.Errorf("Count after delete: got %d, %v, want 2", n, err)
+	}
+}
+
+// TestFakeQdrantScroll pages through more points than qdrantStore asks for at a time.
+func TestFakeQdrantScroll(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeQdrantStore(t)
+
+	if err := store.CreateCollection(ctx, "docs", CollectionConfig{VectorSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var points []Point
+	for i := 1; i <= 2*scrollPage+50; i++ {
+		points = append(points, Point{ID: fmt.Sprint(i), Vector: []float32{float32(i)}, Payload: map[string]any{"even": i%2 == 0}})
+	}
+	if err := store.Upsert(ctx, "docs", points); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := store.Scroll(ctx, "docs", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(points) || all[0].Vector != nil {
+		t.Errorf("Scroll: got %d points, the first with vector %v, want %d without vectors", len(all), all[0].Vector, len(points))
+	}
+	even, err := store.Scroll(ctx, "docs", mustParseFilter(t, "even=true"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(even) != len(points)/2 || even[0].Vector == nil {
+		t.Errorf("Scroll with filter: got %d points, want %d with vectors", len(even), len(points)/2)
+	}
+}
+
+// TestFakeQdrantIngest runs ingestion through the real client: the first run adds, a second changes nothing and
+// a run without a document deletes its point while leaving other sources alone.
+func TestFakeQdrantIngest(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeQdrantStore(t)
+
+	docs := []Document{
+		{Text: "Traditional Italian pizza", Payload: map[string]any{"genre": "food"}},
+		{Text: "The beaches of Bali", Payload: map[string]any{"genre": "travel"}},
+		{Text: "Beethoven's Symphony No. 9", Payload: map[string]any{"genre": "music"}},
+	}
+	steps := []struct {
+		source string
+		docs   []Document
+		want   IngestStats
+	}{
+		{"demo", docs, IngestStats{Added: 3}},
+		{"other", docs[:1], IngestStats{Added: 1}},
+		{"demo", docs, IngestStats{Unchanged: 3}},
+		{"demo", docs[1:], IngestStats{Unchanged: 2, Deleted: 1}},
+	}
+	for i, step := range steps {
+		got, err := ingest(ctx, store, lengthEmbedder{}, "docs", step.source, step.docs)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got != step.want {
+			t.Errorf("step %d: got %s, want %s", i, got, step.want)
+		}
+	}
+
+	for source, want := range map[string]uint64{"demo": 2, "other": 1} {
+		n, err := store.Count(ctx, "docs", sourceFilter(source))
+		if err != nil || n != want {
+			t.Errorf("points of %s: got %d, %v, want %d", source, n, err, want)
+		}
+	}
+}
+
+// TestFakeQdrantScrollOrder pages through the raw Scroll API, which like Qdrant's lists numeric IDs by value and
+// UUIDs after them, so "9" comes before "10" and every page carries on where the last one stopped.
+func TestFakeQdrantScrollOrder(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeQdrantStore(t)
+
+	if err := store.CreateCollection(ctx, "docs", CollectionConfig{VectorSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "2", "9", "10", "11", "99", "100", "1000",
+		"0b7c6c7e-6f0e-4a57-9c5c-0c1f3e0b6c01", "f47ac10b-58cc-4372-a567-0e02b2c3d479"}
+	var points []Point
+	for i := len(want) - 1; i >= 0; i-- {
+		points = append(points, Point{ID: want[i], Vector: []float32{1}})
+	}
+	if err := store.Upsert(ctx, "docs", points); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var offset *qdrant.PointId
+	for pages := 0; ; pages++ {
+		results, next, err := store.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: "docs",
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(3)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			got = append(got, pointIDString(r.GetId()))
+		}
+		if next == nil || pages > len(want) {
+			break
+		}
+		offset = next
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("scrolled %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+)
+
+// Filter restricts a search to points whose payload passes it, with the same meaning as Qdrant's filters:
+// every Must condition has to hold, at least one Should condition has to hold if there are any, and no
+// MustNot condition may hold.
+type Filter struct {
+	Must    []Condition `json:"must,omitempty"`
+	Should  []Condition `json:"should,omitempty"`
+	MustNot []Condition `json:"must_not,omitempty"`
+}
+
+// Condition tests the payload value under Key in exactly one way, or nests a whole Filter.
+// Keys may be dotted paths into nested objects. When the value is a list the condition holds if it holds for
+// any element, so {"key": "tags", "match": "food"} finds points tagged food among others.
+type Condition struct {
+	Key string `json:"key,omitempty"`
+	// Match is a string, an integer or a bool that the value must equal.
+	Match any `json:"match,omitempty"`
+	// Range bounds a numeric value.
+	Range *Range `json:"range,omitempty"`
+	// Exists holds when the key is present with a value that is not null or an empty list.
+	Exists bool    `json:"exists,omitempty"`
+	Filter *Filter `json:"filter,omitempty"`
+}
+
+type Range struct {
+	GT  *float64 `json:"gt,omitempty"`
+	GTE *float64 `json:"gte,omitempty"`
+	LT  *float64 `json:"lt,omitempty"`
+	LTE *float64 `json:"lte,omitempty"`
+}
+
+// parseFilter reads a filter given on the command line, either as JSON in the shape of Filter or as a
+// comma separated list of clauses that must all hold:
+//
+//	genre=food         genre is food
+//	genre!=history     genre is not history
+//	year>=1900         also >, < and <=
+//	genre?             genre is set, !genre? means it is not
+//	genre=food|genre=travel  at least one of the alternatives holds
+//
+// Values that parse as integers or true/false match as such, quote them to match the string instead.
+func parseFilter(expr string) (*Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	var f Filter
+	if strings.HasPrefix(expr, "{") {
+		dec := json.NewDecoder(bytes.NewReader([]byte(expr)))
+		dec.UseNumber()
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		normalizeFilter(&f)
+	} else {
+		for _, clause := range strings.Split(expr, ",") {
+			if err := f.addClause(strings.TrimSpace(clause)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+func (f *Filter) addClause(clause string) error {
+	if alternatives := strings.Split(clause, "|"); len(alternatives) > 1 {
+		var either Filter
+		for _, alt := range alternatives {
+			var one Filter
+			if err := one.addClause(strings.TrimSpace(alt)); err != nil {
+				return err
+			}
+			either.Should = append(either.Should, Condition{Filter: &one})
+		}
+		f.Must = append(f.Must, Condition{Filter: &either})
+		return nil
+	}
+
+	key, op, value, ok := cutOperator(clause)
+	if !ok && strings.HasSuffix(clause, "?") {
+		key, negated := strings.CutPrefix(strings.TrimSuffix(clause, "?"), "!")
+		c := Condition{Key: strings.TrimSpace(key), Exists: true}
+		if negated {
+			f.MustNot = append(f.MustNot, c)
+		} else {
+			f.Must = append(f.Must, c)
+		}
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("invalid filter clause %q", clause)
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+	switch op {
+	case "=":
+		f.Must = append(f.Must, Condition{Key: key, Match: parseMatchValue(value)})
+	case "!=":
+		f.MustNot = append(f.MustNot, Condition{Key: key, Match: parseMatchValue(value)})
+	default:
+		bound, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid filter clause %q: %s needs a number", clause, op)
+		}
+		r := &Range{}
+		switch op {
+		case ">":
+			r.GT = &bound
+		case ">=":
+			r.GTE = &bound
+		case "<":
+			r.LT = &bound
+		case "<=":
+			r.LTE = &bound
+		}
+		f.Must = append(f.Must, Condition{Key: key, Range: r})
+	}
+
+	return nil
+}
+
+// cutOperator splits clause around its first comparison operator, so the value may hold operator characters
+// of its own: title=a<b matches the title "a<b".
+func cutOperator(clause string) (key, op, value string, ok bool) {
+	for i := range len(clause) {
+		for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(clause[i:], op) {
+				return clause[:i], op, clause[i+len(op):], true
+			}
+		}
+	}
+
+	return clause, "", "", false
+}
+
+func parseMatchValue(value string) any {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+
+	return value
+}
+
+// normalizeFilter turns the json.Numbers of a decoded filter into int64 or float64 match values.
+func normalizeFilter(f *Filter) {
+	for _, conds := range [][]Condition{f.Must, f.Should, f.MustNot} {
+		for i := range conds {
+			conds[i].Match = normalizeValue(conds[i].Match)
+			if conds[i].Filter != nil {
+				normalizeFilter(conds[i].Filter)
+			}
+		}
+	}
+}
+
+func (f *Filter) validate() error {
+	for _, conds := range [][]Condition{f.Must, f.Should, f.MustNot} {
+		for _, c := range conds {
+			if err := c.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c Condition) validate() error {
+	kinds := 0
+	for _, set := range []bool{c.Match != nil, c.Range != nil, c.Exists, c.Filter != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return errors.New("invalid filter: a condition needs exactly one of match, range, exists or filter")
+	}
+
+	switch {
+	case c.Filter != nil:
+		return c.Filter.validate()
+	case c.Key == "":
+		return errors.New("invalid filter: condition has no key")
+	case c.Match != nil:
+		switch c.Match.(type) {
+		case string, int64, bool:
+		default:
+			return fmt.Errorf("invalid filter: %s can only match a string, an integer or a bool, use a range for %v", c.Key, c.Match)
+		}
+	case c.Range != nil:
+		if c.Range.GT == nil && c.Range.GTE == nil && c.Range.LT == nil && c.Range.LTE == nil {
+			return fmt.Errorf("invalid filter: range on %s has no bounds", c.Key)
+		}
+	}
+
+	return nil
+}
+
+// matches evaluates the filter against a payload, a nil filter lets everything through.
+func (f *Filter) matches(payload map[string]any) bool {
+	if f == nil {
+		return true
+	}
+	for _, c := range f.Must {
+		if !c.matches(payload) {
+			return false
+		}
+	}
+	for _, c := range f.MustNot {
+		if c.matches(payload) {
+			return false
+		}
+	}
+	if len(f.Should) == 0 {
+		return true
+	}
+	for _, c := range f.Should {
+		if c.matches(payload) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c Condition) matches(payload map[string]any) bool {
+	if c.Filter != nil {
+		return c.Filter.matches(payload)
+	}
+
+	value, ok := payloadValue(payload, c.Key)
+	if c.Exists {
+		list, isList := value.([]any)
+		return ok && value != nil && (!isList || len(list) > 0)
+	}
+	if !ok {
+		return false
+	}
+
+	values := []any{value}
+	if list, isList := value.([]any); isList {
+		values = list
+	}
+	for _, v := range values {
+		if c.Match != nil && matchValue(c.Match, v) {
+			return true
+		}
+		if c.Range != nil && c.Range.contains(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func payloadValue(payload map[string]any, key string) (any, bool) {
+	var value any = payload
+	for part := range strings.SplitSeq(key, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func matchValue(want, got any) bool {
+	switch want := want.(type) {
+	case string:
+		s, ok := got.(string)
+		return ok && s == want
+	case bool:
+		b, ok := got.(bool)
+		return ok && b == want
+	case int64:
+		// Like Qdrant's integer match, a float payload value never matches, not even 3.0.
+		switch got := got.(type) {
+		case int:
+			return int64(got) == want
+		case int64:
+			return got == want
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func (r *Range) contains(v any) bool {
+	f, ok := toFloat(v)
+	if !ok || math.IsNaN(f) {
+		return false
+	}
+
+	return (r.GT == nil || f > *r.GT) &&
+		(r.GTE == nil || f >= *r.GTE) &&
+		(r.LT == nil || f < *r.LT) &&
+		(r.LTE == nil || f <= *r.LTE)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// qdrantFilter translates a filter into Qdrant's protobuf form, exists becomes "must not be empty".
+func qdrantFilter(f *Filter) *qdrant.Filter {
+	if f == nil {
+		return nil
+	}
+
+	return &qdrant.Filter{
+		Must:    qdrantConditions(f.Must),
+		Should:  qdrantConditions(f.Should),
+		MustNot: qdrantConditions(f.MustNot),
+	}
+}
+
+func qdrantConditions(conds []Condition) []*qdrant.Condition {
+	result := make([]*qdrant.Condition, 0, len(conds))
+	for _, c := range conds {
+		result = append(result, qdrantCondition(c))
+	}
+
+	return result
+}
+
+func qdrantCondition(c Condition) *qdrant.Condition {
+	switch {
+	case c.Filter != nil:
+		return qdrant.NewFilterAsCondition(qdrantFilter(c.Filter))
+	case c.Exists:
+		return qdrant.NewFilterAsCondition(&qdrant.Filter{
+			MustNot: []*qdrant.Condition{qdrant.NewIsEmpty(c.Key)},
+		})
+	case c.Range != nil:
+		return qdrant.NewRange(c.Key, &qdrant.Range{
+			Gt:  c.Range.GT,
+			Gte: c.Range.GTE,
+			Lt:  c.Range.LT,
+			Lte: c.Range.LTE,
+		})
+	}
+
+	switch v := c.Match.(type) {
+	case int64:
+		return qdrant.NewMatchInt(c.Key, v)
+	case bool:
+		return qdrant.NewMatchBool(c.Key, v)
+	default:
+		return qdrant.NewMatchKeyword(c.Key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func float(f float64) *float64 { return &f }
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want *Filter
+	}{
+		{"", nil},
+		{"genre=food", &Filter{Must: []Condition{{Key: "genre", Match: "food"}}}},
+		{" year = 1900 ", &Filter{Must: []Condition{{Key: "year", Match: int64(1900)}}}},
+		{`year="1900"`, &Filter{Must: []Condition{{Key: "year", Match: "1900"}}}},
+		{"draft=true", &Filter{Must: []Condition{{Key: "draft", Match: true}}}},
+		{"genre!=history", &Filter{MustNot: []Condition{{Key: "genre", Match: "history"}}}},
+		{"year>=1900,year<2000", &Filter{Must: []Condition{
+			{Key: "year", Range: &Range{GTE: float(1900)}},
+			{Key: "year", Range: &Range{LT: float(2000)}},
+		}}},
+		{"score>0.5,score<=1", &Filter{Must: []Condition{
+			{Key: "score", Range: &Range{GT: float(0.5)}},
+			{Key: "score", Range: &Range{LTE: float(1)}},
+		}}},
+		{"genre?,!meta.draft?", &Filter{
+			Must:    []Condition{{Key: "genre", Exists: true}},
+			MustNot: []Condition{{Key: "meta.draft", Exists: true}},
+		}},
+		// The first operator splits the clause, whatever the value holds.
+		{"title=a<b", &Filter{Must: []Condition{{Key: "title", Match: "a<b"}}}},
+		{"title=x>=y", &Filter{Must: []Condition{{Key: "title", Match: "x>=y"}}}},
+		{"title!=a=b", &Filter{MustNot: []Condition{{Key: "title", Match: "a=b"}}}},
+		{"title=what?", &Filter{Must: []Condition{{Key: "title", Match: "what?"}}}},
+		{"genre=food|genre=travel", &Filter{Must: []Condition{{Filter: &Filter{Should: []Condition{
+			{Filter: &Filter{Must: []Condition{{Key: "genre", Match: "food"}}}},
+			{Filter: &Filter{Must: []Condition{{Key: "genre", Match: "travel"}}}},
+		}}}}}},
+		{`{"must": [{"key": "year", "match": 1900}], "must_not": [{"key": "score", "range": {"gt": 0.5}}]}`, &Filter{
+			Must:    []Condition{{Key: "year", Match: int64(1900)}},
+			MustNot: []Condition{{Key: "score", Range: &Range{GT: float(0.5)}}},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFilter(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"genre",
+		"year>=soon",
+		"=food",
+		"genre=food,",
+		`{"must": [{"key": "score", "match": 0.5}]}`,
+		`{"must": [{"key": "year", "match": 1, "range": {"gt": 0}}]}`,
+		`{"must": [{"key": "year", "range": {}}]}`,
+		`{"must": [{"match": "food"}]}`,
+		`{"must": [{"key": "genre", "equals": "food"}]}`,
+		`{"must": [`,
+	} {
+		if f, err := parseFilter(expr); err == nil {
+			t.Errorf("parseFilter(%q) = %+v, want an error", expr, f)
+		}
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	payload := map[string]any{
+		"genre": "food",
+		"year":  int64(1923),
+		"score": 0.75,
+		"draft": false,
+		"tags":  []any{"soup", "winter"},
+		"empty": []any{},
+		"none":  nil,
+		"meta":  map[string]any{"pages": 12, "author": "Ann"},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"genre=food", true},
+		{"genre=travel", false},
+		{"genre!=travel", true},
+		{"year=1923", true},
+		{`year="1923"`, false},
+		{"year>=1900,year<2000", true},
+		{"year>1923", false},
+		{"score>0.5,score<=0.75", true},
+		{"genre>1", false},
+		{"draft=false", true},
+		{"tags=winter", true},
+		{"tags=summer", false},
+		{"tags?", true},
+		{"empty?", false},
+		{"none?", false},
+		{"missing?", false},
+		{"!missing?", true},
+		{"meta.pages=12", true},
+		{"meta.author=Ann,meta.pages<10", false},
+		{"genre.name?", false},
+		{"genre=travel|year=1923", true},
+		{"genre=travel|year=1924", false},
+		// An integer match only holds for integers, as in Qdrant.
+		{"score=1", false},
+		{`{"must": [{"key": "score", "range": {"gte": 0.75}}]}`, true},
+		{`{"should": [{"key": "genre", "match": "travel"}, {"key": "tags", "match": "soup"}]}`, true},
+		{`{"should": [{"key": "genre", "match": "travel"}], "must_not": [{"key": "draft", "match": true}]}`, false},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Fatalf("parseFilter(%q): %v", tt.expr, err)
+		}
+		if got := f.matches(payload); got != tt.want {
+			t.Errorf("%s matches = %t, want %t", tt.expr, got, tt.want)
+		}
+	}
+
+	var none *Filter
+	if !none.matches(payload) {
+		t.Error("a nil filter rejected a payload")
+	}
+}
+
+// TestMatchIntIgnoresFloats checks that the memory store matches integers the way Qdrant's integer match does,
+// so a float payload value is never equal to an integer however whole it is.
+func TestMatchIntIgnoresFloats(t *testing.T) {
+	f, err := parseFilter("n=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := qdrantFilter(f).GetMust()[0].GetField().GetMatch().GetInteger(); got != 3 {
+		t.Fatalf("n=3 is not an integer match for Qdrant")
+	}
+
+	for _, tt := range []struct {
+		value any
+		want  bool
+	}{
+		{int64(3), true},
+		{3, true},
+		{3.0, false},
+		{float32(3), false},
+		{"3", false},
+		{int64(4), false},
+	} {
+		if got := f.matches(map[string]any{"n": tt.value}); got != tt.want {
+			t.Errorf("n=3 against %T %v = %t, want %t", tt.value, tt.value, got, tt.want)
+		}
+	}
+}
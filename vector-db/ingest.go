@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Ingestion is idempotent. A point's ID is a UUID made from a hash of its source and text, so the same
+// document always lands on the same point, and its payload carries a hash of everything stored with it.
+// Re-running an ingest only embeds text the collection hasn't seen, rewrites payloads that changed and
+// deletes the points of this source whose documents are gone. Other sources in the collection are left alone.
+// A text that appears more than once in a source, like "Exeunt." in a play, is one point with the payload of
+// its first occurrence, the later ones are counted as duplicates and skipped.
+
+const (
+	payloadText        = "text"
+	payloadSource      = "source"
+	payloadContentHash = "content_hash"
+)
+
+// Document is one text to embed and the payload to store beside it.
+type Document struct {
+	Text    string
+	Payload map[string]any
+}
+
+type IngestStats struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Deleted   int
+	// Duplicates counts documents skipped because an earlier document of the source had the same text.
+	Duplicates int
+}
+
+func (s IngestStats) String() string {
+	stats := fmt.Sprintf("%d added, %d updated, %d unchanged, %d deleted", s.Added, s.Updated, s.Unchanged, s.Deleted)
+	if s.Duplicates > 0 {
+		stats += fmt.Sprintf(", %d duplicates skipped", s.Duplicates)
+	}
+
+	return stats
+}
+
+// Embedder is the part of an embedding model ingestion needs.
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ingest makes the points of source in collection match docs, creating the collection on first use.
+func ingest(ctx context.Context, store VectorStore, embedder Embedder, collection, source string, docs []Document) (IngestStats, error) {
+	in, err := newIngester(ctx, store, embedder, collection, source)
+	if err != nil {
+		return IngestStats{}, err
+	}
+	if err := in.add(ctx, docs); err != nil {
+		return in.stats, err
+	}
+
+	return in.finish(ctx)
+}
+
+// ingester ingests a source in batches so a large file never has to be in memory at once.
+// Only the IDs and content hashes of the source's points are kept between batches.
+type ingester struct {
+	store      VectorStore
+	embedder   Embedder
+	collection string
+	source     string
+
+	config CollectionConfig
+	exists bool
+	// stored maps the IDs of the source's points to their content hash.
+	stored map[string]string
+	// seen holds the IDs of every document added so far, across batches.
+	seen  map[string]bool
+	stats IngestStats
+}
+
+func newIngester(ctx context.Context, store VectorStore, embedder Embedder, collection, source string) (*ingester, error) {
+	in := &ingester{
+		store:      store,
+		embedder:   embedder,
+		collection: collection,
+		source:     source,
+		stored:     make(map[string]string),
+		seen:       make(map[string]bool),
+	}
+
+	config, err := store.Collection(ctx, collection)
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return nil, err
+	}
+	in.config, in.exists = config, err == nil
+
+	if in.exists {
+		points, err := store.Scroll(ctx, collection, sourceFilter(source), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stored points: %w", err)
+		}
+		for _, p := range points {
+			hash, _ := p.Payload[payloadContentHash].(string)
+			in.stored[p.ID] = hash
+		}
+	}
+
+	return in, nil
+}
+
+// add stores one batch of documents, embedding only the text the collection hasn't seen.
+func (in *ingester) add(ctx context.Context, docs []Document) error {
+	// The first occurrence of a text wins, in this batch or an earlier one, so every run stores the same payload.
+	wanted := make(map[string]Point, len(docs))
+	var order []string
+	for i, doc := range docs {
+		p, err := newDocumentPoint(in.source, doc)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
+		if in.seen[p.ID] {
+			in.stats.Duplicates++
+			continue
+		}
+		in.seen[p.ID] = true
+		order = append(order, p.ID)
+		wanted[p.ID] = p
+	}
+
+	var added, changed []string
+	for _, id := range order {
+		hash, ok := in.stored[id]
+		switch {
+		case !ok:
+			added = append(added, id)
+		case hash != wanted[id].Payload[payloadContentHash]:
+			changed = append(changed, id)
+		default:
+			in.stats.Unchanged++
+		}
+	}
+
+	var upserts []Point
+	updated := 0
+	if len(changed) > 0 {
+		// Same ID means same text, so only the payload moved and the stored vector can be reused. A point deleted
+		// since the ingest started has no vector to reuse and is embedded again.
+		current, err := in.store.Get(ctx, in.collection, changed)
+		if err != nil {
+			return fmt.Errorf("failed to read changed points: %w", err)
+		}
+		found := make(map[string]bool, len(current))
+		for _, p := range current {
+			update := wanted[p.ID]
+			update.Vector = p.Vector
+			upserts = append(upserts, update)
+			found[p.ID] = true
+		}
+		for _, id := range changed {
+			if !found[id] {
+				added = append(added, id)
+			}
+		}
+		updated = len(current)
+	}
+
+	if len(added) > 0 {
+		texts := make([]string, 0, len(added))
+		for _, id := range added {
+			texts = append(texts, wanted[id].Payload[payloadText].(string))
+		}
+		vectors, err := in.embedder.EmbedDocuments(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed documents: %w", err)
+		}
+		if len(vectors) != len(texts) {
+			return fmt.Errorf("embedder returned %d vectors for %d documents", len(vectors), len(texts))
+		}
+		if err := in.ensureCollection(ctx, uint64(len(vectors[0]))); err != nil {
+			return err
+		}
+
+		for i, id := range added {
+			p := wanted[id]
+			p.Vector = vectors[i]
+			upserts = append(upserts, p)
+		}
+	}
+
+	if len(upserts) > 0 {
+		if err := in.store.Upsert(ctx, in.collection, upserts); err != nil {
+			return err
+		}
+	}
+	for _, p := range upserts {
+		in.stored[p.ID] = p.Payload[payloadContentHash].(string)
+	}
+	in.stats.Added += len(added)
+	in.stats.Updated += updated
+
+	return nil
+}
+
+// ensureCollection creates the collection for dim dimensional vectors, or checks that an existing one takes them.
+func (in *ingester) ensureCollection(ctx context.Context, dim uint64) error {
+	if !in.exists {
+		in.config = CollectionConfig{VectorSize: dim, Distance: DistanceCosine}
+		if err := in.store.CreateCollection(ctx, in.collection, in.config); err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", in.collection, err)
+		}
+		in.exists = true
+		return nil
+	}
+	if in.config.VectorSize != dim {
+		return fmt.Errorf("collection %s holds %d dimensional vectors but the embedder returns %d, use another collection or drop it first",
+			in.collection, in.config.VectorSize, dim)
+	}
+
+	return nil
+}
+
+// finish deletes the source's points that no batch mentioned, their documents are gone.
+func (in *ingester) finish(ctx context.Context) (IngestStats, error) {
+	var removed []string
+	for id := range in.stored {
+		if !in.seen[id] {
+			removed = append(removed, id)
+		}
+	}
+	if len(removed) > 0 {
+		if err := in.store.Delete(ctx, in.collection, removed); err != nil {
+			return in.stats, err
+		}
+	}
+	in.stats.Deleted = len(removed)
+
+	return in.stats, nil
+}
+
+// newDocumentPoint builds the point for doc without its vector, text and source are added to the payload.
+func newDocumentPoint(source string, doc Document) (Point, error) {
+	payload := make(map[string]any, len(doc.Payload)+3)
+	for k, v := range doc.Payload {
+		payload[k] = v
+	}
+	payload[payloadText] = doc.Text
+	payload[payloadSource] = source
+	hash, err := contentHash(payload)
+	if err != nil {
+		return Point{}, err
+	}
+	payload[payloadContentHash] = hash
+
+	return Point{
+		ID:      contentID(source, doc.Text),
+		Payload: payload,
+	}, nil
+}
+
+// contentID is a version 5 style UUID from the SHA-256 of source and text, so it is stable across runs
+// and valid as a Qdrant point ID.
+func contentID(source, text string) string {
+	sum := sha256.Sum256([]byte(source + "\x00" + text))
+	u := sum[:16]
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// contentHash hashes the payload as JSON, which encodes map keys in sorted order.
+func contentHash(payload map[string]any) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("payload can't be stored: %w", err)
+	}
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sourceFilter(source string) *Filter {
+	return &Filter{Must: []Condition{{Key: payloadSource, Match: source}}}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// lengthEmbedder embeds a text as its length and a constant, enough for ingestion which never compares vectors.
+type lengthEmbedder struct{}
+
+func (lengthEmbedder) EmbedDocuments(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), 1}
+	}
+
+	return vectors, nil
+}
+
+// TestIngestDuplicatesAcrossBatches ingests a play's lines where "Exeunt." repeats in different batches with a
+// different line number each time. The first occurrence is stored and re-ingesting changes nothing.
+func TestIngestDuplicatesAcrossBatches(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	batches := [][]Document{
+		{{Text: "Enter Macbeth.", Payload: map[string]any{"line": 1}}, {Text: "Exeunt.", Payload: map[string]any{"line": 2}}},
+		{{Text: "Exeunt.", Payload: map[string]any{"line": 3}}, {Text: "Thunder.", Payload: map[string]any{"line": 4}}},
+		{{Text: "Exeunt.", Payload: map[string]any{"line": 5}}},
+	}
+	run := func() IngestStats {
+		in, err := newIngester(ctx, store, lengthEmbedder{}, "lines", "macbeth.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, batch := range batches {
+			if err := in.add(ctx, batch); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stats, err := in.finish(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	if got, want := run(), (IngestStats{Added: 3, Duplicates: 2}); got != want {
+		t.Errorf("first ingest: got %s, want %s", got, want)
+	}
+	if got, want := run(), (IngestStats{Unchanged: 3, Duplicates: 2}); got != want {
+		t.Errorf("second ingest: got %s, want %s", got, want)
+	}
+
+	points, err := store.Get(ctx, "lines", []string{contentID("macbeth.txt", "Exeunt.")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].Payload["line"] != 2 {
+		t.Errorf("got %v, want the first occurrence at line 2", points)
+	}
+}
+
+// TestIngestChangedPointDeleted changes the payload of a point that is deleted after the ingest listed it, so
+// there is no stored vector to reuse. It is embedded again and counted as added, not updated.
+func TestIngestChangedPointDeleted(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	docs := []Document{
+		{Text: "Enter Macbeth.", Payload: map[string]any{"line": 1}},
+		{Text: "Thunder.", Payload: map[string]any{"line": 2}},
+	}
+	if _, err := ingest(ctx, store, lengthEmbedder{}, "lines", "macbeth.txt", docs); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := newIngester(ctx, store, lengthEmbedder{}, "lines", "macbeth.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "lines", []string{contentID("macbeth.txt", "Thunder.")}); err != nil {
+		t.Fatal(err)
+	}
+	docs[0].Payload, docs[1].Payload = map[string]any{"line": 10}, map[string]any{"line": 20}
+	if err := in.add(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := in.finish(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (IngestStats{Added: 1, Updated: 1}); stats != want {
+		t.Errorf("got %s, want %s", stats, want)
+	}
+
+	points, err := store.Scroll(ctx, "lines", nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want 2", len(points))
+	}
+	for _, p := range points {
+		if line := p.Payload["line"]; line != 10 && line != 20 || len(p.Vector) != 2 {
+			t.Errorf("point %s has line %v and vector %v", p.ID, line, p.Vector)
+		}
+	}
+}
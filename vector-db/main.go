@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	qdrant "github.com/qdrant/go-client/qdrant"
 	"github.com/tmc/langchaingo/embeddings"
@@ -13,8 +15,10 @@ import (
 
 const (
 	CollectionName = "demo_docs"
-	QdrantHost     = "localhost"
-	QdrantPort     = 6334
+	// EmbeddingModel returns 3072 dimensional vectors, the default size of collections create.
+	EmbeddingModel = "text-embedding-3-large"
+	// demoSource tags the built-in documents below so re-ingesting them never touches other points.
+	demoSource = "demo"
 )
 
 var documents = []string{
@@ -43,118 +47,205 @@ var genres = []string{
 }
 
 type Application struct {
-	qdrant *qdrant.Client
+	store VectorStore
+	// collection is the collection every command works on.
+	collection string
+	// embeddingBaseURL overrides the OpenAI API base URL, e.g. to use ../embedding-server.
+	embeddingBaseURL string
+	format           string
 }
 
 // docker run -p 6333:6333 -p 6334:6334 qdrant/qdrant
 
+const usage = `usage: vector-db [flags] <command> [command flags] [arguments]
+
+commands:
+  ingest [file]                       embed and store a file, one document per line, or the built-in demo documents
+  query <text>                        search the collection, e.g. query -limit 3 -filter genre=food delicious food
+  delete -filter <filter>             delete the points that pass a filter
+  collections list                    list collections
+  collections create <name>           create an empty collection, see -size and -distance
+  collections drop <name>             drop a collection and all of its points
+  stats                               show the point count, vector size and distance of the collection
+  config show                         print the effective configuration with the API key redacted
+
+Qdrant connection settings come from, lowest precedence first, their defaults, the -config file,
+environment variables and flags. A config file looks like:
+
+  collection = "demo_docs"
+
+  [qdrant]
+  host = "qdrant.staging.internal"
+  port = 6334
+  tls = true
+  timeout = "10s"
+
+Commands take their own flags, run vector-db <command> -h to see them. Flags:
+`
+
 func main() {
-	client, err := qdrant.NewClient(&qdrant.Config{
-		Host: QdrantHost,
-		Port: QdrantPort,
-	})
-	if err != nil {
+	baseURL := flag.String("base-url", "", "base URL of an OpenAI compatible API, e.g. http://localhost:8089/v1 for ../embedding-server")
+	format := flag.String("format", FormatText, "output format: text, json or jsonl")
+	configPath := flag.String("config", "", "TOML config file for the Qdrant connection and collection (VECTOR_DB_CONFIG)")
+	configFlags := registerConfigFlags(flag.CommandLine)
+	storeKind := flag.String("store", StoreQdrant, "vector store: qdrant, memory for an in-process store that needs no server, or disk to keep it between runs")
+	dataDir := flag.String("data-dir", "vector-db-data", "directory for the disk store")
+	syncPolicy := flag.String("fsync", SyncAlways, "when the disk store flushes its log: always, interval or never")
+	syncInterval := flag.Duration("fsync-interval", time.Second, "how often the disk store flushes its log with -fsync interval")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := validateFormat(*format); err != nil {
 		log.Fatal(err)
 	}
+	config, err := loadConfig(*configPath, flag.CommandLine, configFlags)
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	if command == "config" {
+		// Runs before any store is opened, so it works even when Qdrant can't be reached.
+		runConfig(config, args)
+		return
+	}
+
+	var store VectorStore
+	switch *storeKind {
+	case StoreQdrant:
+		qdrantConfig, err := config.qdrantConfig()
+		if err != nil {
+			log.Fatalf("failed to configure Qdrant: %v", err)
+		}
+		client, err := qdrant.NewClient(qdrantConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store = newQdrantStore(client)
+	case StoreMemory:
+		store = newMemoryStore()
+	case StoreDisk:
+		opts := defaultDiskOptions()
+		opts.Sync = *syncPolicy
+		opts.SyncInterval = *syncInterval
+		store, err = newDiskStore(*dataDir, opts)
+		if err != nil {
+			log.Fatalf("failed to open %s: %v", *dataDir, err)
+		}
+	default:
+		log.Fatalf("unknown store %q (want %s, %s or %s)", *storeKind, StoreQdrant, StoreMemory, StoreDisk)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Printf("failed to close store: %v", err)
+		}
+	}()
 
 	app := &Application{
-		qdrant: client,
+		store:            store,
+		collection:       config.Collection,
+		embeddingBaseURL: *baseURL,
+		format:           *format,
+	}
+
+	if *storeKind == StoreMemory && command == "query" {
+		// Nothing survives between runs, so a query starts with the demo documents to have something to search.
+		// Only a query needs them, the other commands run without embedding anything.
+		app.embedVectorsAndStoreInDB(demoSource, demoDocuments())
 	}
 
-	// Run only if data hasn't been persisted already
-	// app.embedVectorsAndStoreInDB(documents, genres)
+	switch command {
+	case "ingest":
+		app.runIngest(args)
+	case "query":
+		app.runQuery(args)
+	case "delete":
+		app.runDelete(args)
+	case "collections":
+		app.runCollections(args)
+	case "stats":
+		app.runStats(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// demoDocuments pairs the built-in documents with their genres.
+func demoDocuments() []Document {
+	docs := make([]Document, 0, len(documents))
+	for i := range documents {
+		docs = append(docs, Document{
+			Text:    documents[i],
+			Payload: map[string]any{"genre": genres[i]},
+		})
+	}
 
-	query := "Tell me about some delicious food"
-	app.queryQdrant(query)
+	return docs
 }
 
-func (app *Application) queryQdrant(query string) {
+func (app *Application) queryQdrant(query string, search SearchQuery) []Result {
 	ctx := context.Background()
 
-	embedder := getEmbedder()
+	embedder := getEmbedder(app.embeddingBaseURL)
 
 	queryVec, err := embedder.EmbedQuery(ctx, query)
 	if err != nil {
 		log.Fatalf("failed to embed query: %v", err)
 	}
 
-	results, err := app.qdrant.GetPointsClient().Search(ctx, &qdrant.SearchPoints{
-		CollectionName: CollectionName,
-		Vector:         queryVec,
-		Limit:          2,
-		WithPayload:    qdrant.NewWithPayload(true),
-	})
+	search.Vector = queryVec
+	results, err := app.store.Search(ctx, app.collection, search)
 	if err != nil {
 		log.Fatalf("search failed: %v", err)
 	}
 
-	for _, result := range results.Result {
-		fmt.Println("RESULT")
-		for key, value := range result.Payload {
-			fmt.Printf("Key: %s\nValue: %v\n", key, value)
-		}
-		fmt.Printf("Result (score=%.4f)\nText: %s\nGenre: %s\n", result.Score, result.Payload["text"], result.Payload["genre"])
-		fmt.Println()
+	var ranked []Result
+	for i, result := range results {
+		ranked = append(ranked, newResult(i+1, result, app.collection))
 	}
 
+	return ranked
 }
 
-func (app *Application) embedVectorsAndStoreInDB(documents, genres []string) {
+func (app *Application) embedVectorsAndStoreInDB(source string, docs []Document) {
 	ctx := context.Background()
 
-	embedder := getEmbedder()
-
-	vectors, err := embedder.EmbedDocuments(ctx, documents)
-	if err != nil {
-		log.Fatalf("failed to embed documents: %v", err)
-	}
-
-	vectorSize := len(vectors[0])
+	embedder := getEmbedder(app.embeddingBaseURL)
 
-	err = app.qdrant.CreateCollection(ctx, &qdrant.CreateCollection{
-		CollectionName: CollectionName,
-		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
-			Size:     uint64(vectorSize),
-			Distance: qdrant.Distance_Cosine,
-		}),
-	})
-	if err != nil {
-		log.Println("collection may already exist:", err)
-	}
-
-	points := make([]*qdrant.PointStruct, 0, len(documents))
-
-	for i := range documents {
-		points = append(points, &qdrant.PointStruct{
-			Id:      qdrant.NewIDNum(uint64(i)),
-			Vectors: qdrant.NewVectors(vectors[i]...),
-			Payload: qdrant.NewValueMap(map[string]any{
-				"genre": genres[i],
-				"text":  documents[i],
-			}),
-		})
-	}
-
-	_, err = app.qdrant.Upsert(ctx, &qdrant.UpsertPoints{
-		CollectionName: CollectionName,
-		Points:         points,
-	})
+	stats, err := ingest(ctx, app.store, embedder, app.collection, source, docs)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	fmt.Println("Documents embedded and stored in Qdrant")
+	fmt.Fprintf(os.Stderr, "%s: %s\n", app.collection, stats)
 }
 
-func getEmbedder() *embeddings.EmbedderImpl {
+func getEmbedder(baseURL string) *embeddings.EmbedderImpl {
 	if os.Getenv("OPENAI_API_KEY") == "" {
 		log.Fatalf("OPEN_API_KEY environment variable not set")
 	}
 
+	// WithModel only picks the chat model, without WithEmbeddingModel langchaingo embeds with
+	// text-embedding-ada-002 and its 1536 dimensions, which don't fit a collection made with the default -size.
+	opts := []openai.Option{
+		openai.WithModel(EmbeddingModel),
+		openai.WithEmbeddingModel(EmbeddingModel),
+	}
+	if baseURL != "" {
+		opts = append(opts, openai.WithBaseURL(baseURL))
+	}
+
 	// openai.New automatically checks OPENAI_API_KEY env var
-	llm, err := openai.New(
-		openai.WithModel("text-embedding-3-large"),
-	)
+	llm, err := openai.New(opts...)
 	if err != nil {
 		log.Fatalf("failed to create OpenAI client: %v", err)
 	}
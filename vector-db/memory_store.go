@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// memoryStore is a VectorStore that lives in process memory and searches by comparing the query to every vector.
+// It needs no server which makes it handy for trying things out, everything is gone when the process exits.
+type memoryStore struct {
+	mu          sync.RWMutex
+	collections map[string]*memoryCollection
+}
+
+type memoryCollection struct {
+	config CollectionConfig
+	points map[string]Point
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		collections: make(map[string]*memoryCollection),
+	}
+}
+
+func (s *memoryStore) CreateCollection(_ context.Context, name string, config CollectionConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[name]; ok {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+	s.collections[name] = &memoryCollection{
+		config: config,
+		points: make(map[string]Point),
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Collection(_ context.Context, name string) (CollectionConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.collection(name)
+	if err != nil {
+		return CollectionConfig{}, err
+	}
+
+	return c.config, nil
+}
+
+func (s *memoryStore) Upsert(_ context.Context, collection string, points []Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	if err := checkDimensions(c, collection, points); err != nil {
+		return err
+	}
+	for _, p := range points {
+		c.points[p.ID] = clonePoint(p)
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Search(_ context.Context, collection string, query SearchQuery) ([]ScoredPoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(query.Vector)) != c.config.VectorSize {
+		return nil, fmt.Errorf("query has %d dimensions, collection %s expects %d", len(query.Vector), collection, c.config.VectorSize)
+	}
+
+	scored := make([]ScoredPoint, 0, len(c.points))
+	for _, p := range c.points {
+		if !query.Filter.matches(p.Payload) {
+			continue
+		}
+		sp := ScoredPoint{
+			Point: Point{ID: p.ID, Payload: p.Payload},
+			Score: score(c.config.Distance, query.Vector, p.Vector),
+		}
+		if t := query.ScoreThreshold; t != nil && worseThan(c.config.Distance, sp.Score, *t) {
+			continue
+		}
+		scored = append(scored, sp)
+	}
+	sortScored(scored, c.config.Distance)
+
+	if uint64(len(scored)) > query.Limit {
+		scored = scored[:query.Limit]
+	}
+
+	return scored, nil
+}
+
+func (s *memoryStore) Collections(_ context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *memoryStore) DropCollection(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.collection(name); err != nil {
+		return err
+	}
+	delete(s.collections, name)
+
+	return nil
+}
+
+func (s *memoryStore) Count(_ context.Context, collection string, filter *Filter) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	var n uint64
+	for _, p := range c.points {
+		if filter.matches(p.Payload) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, collection string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(c.points, id)
+	}
+
+	return nil
+}
+
+func (s *memoryStore) Get(_ context.Context, collection string, ids []string) ([]Point, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	for _, id := range ids {
+		if p, ok := c.points[id]; ok {
+			points = append(points, clonePoint(p))
+		}
+	}
+
+	return points, nil
+}
+
+func (s *memoryStore) Scroll(_ context.Context, collection string, filter *Filter, withVectors bool) ([]Point, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	for _, p := range c.points {
+		if !filter.matches(p.Payload) {
+			continue
+		}
+		p = clonePoint(p)
+		if !withVectors {
+			p.Vector = nil
+		}
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].ID < points[j].ID })
+
+	return points, nil
+}
+
+func (s *memoryStore) Close() error { return nil }
+
+// checkUpsert reports the error Upsert would return without storing anything.
+func (s *memoryStore) checkUpsert(collection string, points []Point) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+
+	return checkDimensions(c, collection, points)
+}
+
+// hasCollection reports whether a collection exists.
+func (s *memoryStore) hasCollection(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.collections[name]
+	return ok
+}
+
+func checkDimensions(c *memoryCollection, collection string, points []Point) error {
+	for _, p := range points {
+		if uint64(len(p.Vector)) != c.config.VectorSize {
+			return fmt.Errorf("point %s has %d dimensions, collection %s expects %d", p.ID, len(p.Vector), collection, c.config.VectorSize)
+		}
+	}
+
+	return nil
+}
+
+// collection looks up a collection, callers must hold s.mu.
+func (s *memoryStore) collection(name string) (*memoryCollection, error) {
+	c, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+
+	return c, nil
+}
+
+// clonePoint copies the vector and the top level of the payload so callers can't change stored points.
+func clonePoint(p Point) Point {
+	payload := make(map[string]any, len(p.Payload))
+	for k, v := range p.Payload {
+		payload[k] = v
+	}
+
+	return Point{
+		ID:      p.ID,
+		Vector:  append([]float32(nil), p.Vector...),
+		Payload: payload,
+	}
+}
+
+// score follows Qdrant: cosine similarity and dot product are better when higher, euclid is a distance.
+func score(distance Distance, a, b []float32) float32 {
+	var dot, normA, normB, sq float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		normA += x * x
+		normB += y * y
+		sq += (x - y) * (x - y)
+	}
+
+	switch distance {
+	case DistanceDot:
+		return float32(dot)
+	case DistanceEuclid:
+		return float32(math.Sqrt(sq))
+	default:
+		if normA == 0 || normB == 0 {
+			return 0
+		}
+		return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+	}
+}
+
+// worseThan reports whether a score falls on the wrong side of a threshold.
+func worseThan(distance Distance, score, threshold float32) bool {
+	if distance == DistanceEuclid {
+		return score > threshold
+	}
+
+	return score < threshold
+}
+
+// sortScored puts the best hits first, breaking ties by ID so results are stable between runs.
+func sortScored(scored []ScoredPoint, distance Distance) {
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			if distance == DistanceEuclid {
+				return scored[i].Score < scored[j].Score
+			}
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].ID < scored[j].ID
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+)
+
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+// Result is one ranked search hit in a form scripts can consume without scraping the text output.
+type Result struct {
+	// Query is only set in JSONL output where every line has to stand on its own.
+	Query string  `json:"query,omitempty"`
+	Rank  int     `json:"rank"`
+	Score float64 `json:"score"`
+	ID    string  `json:"id"`
+	Text  string  `json:"text"`
+	// Source is the source the point was ingested from, like a Result's file in simple-embedding.
+	Source     string         `json:"source"`
+	Collection string         `json:"collection"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+}
+
+type resultSet struct {
+	Query   string   `json:"query"`
+	Results []Result `json:"results"`
+}
+
+func validateFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON, FormatJSONL:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (want %s, %s or %s)", format, FormatText, FormatJSON, FormatJSONL)
+	}
+}
+
+// newResult lifts "text" and "source" out of the payload, every other payload field except ingestion's content hash
+// becomes metadata.
+func newResult(rank int, point ScoredPoint, collection string) Result {
+	metadata := make(map[string]any, len(point.Payload))
+	for key, value := range point.Payload {
+		metadata[key] = value
+	}
+	text, _ := metadata[payloadText].(string)
+	source, _ := metadata[payloadSource].(string)
+	delete(metadata, payloadText)
+	delete(metadata, payloadSource)
+	delete(metadata, payloadContentHash)
+
+	return Result{
+		Rank:       rank,
+		Score:      float64(point.Score),
+		ID:         point.ID,
+		Text:       text,
+		Source:     source,
+		Collection: collection,
+		Metadata:   metadata,
+	}
+}
+
+func printResults(w io.Writer, format, query string, results []Result) {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(resultSet{Query: query, Results: results}); err != nil {
+			log.Fatalf("failed to write results: %v", err)
+		}
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, r := range results {
+			r.Query = query
+			if err := enc.Encode(r); err != nil {
+				log.Fatalf("failed to write results: %v", err)
+			}
+		}
+	default:
+		for _, r := range results {
+			fmt.Fprintf(w, "%d) score=%.4f | %s\n", r.Rank, r.Score, r.Text)
+			if r.Source != "" {
+				fmt.Fprintf(w, "   source: %s\n", r.Source)
+			}
+			keys := make([]string, 0, len(r.Metadata))
+			for key := range r.Metadata {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(w, "   %s: %v\n", key, r.Metadata[key])
+			}
+		}
+	}
+}
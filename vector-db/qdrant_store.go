@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	qdrant "github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// qdrantStore is a VectorStore backed by a Qdrant server.
+type qdrantStore struct {
+	client *qdrant.Client
+}
+
+func newQdrantStore(client *qdrant.Client) *qdrantStore {
+	return &qdrantStore{client: client}
+}
+
+func (s *qdrantStore) CreateCollection(ctx context.Context, name string, config CollectionConfig) error {
+	err := s.client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     config.VectorSize,
+			Distance: qdrantDistance(config.Distance),
+		}),
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	return err
+}
+
+func (s *qdrantStore) Collection(ctx context.Context, name string) (CollectionConfig, error) {
+	exists, err := s.client.CollectionExists(ctx, name)
+	if err != nil {
+		return CollectionConfig{}, err
+	}
+	if !exists {
+		return CollectionConfig{}, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+
+	info, err := s.client.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return CollectionConfig{}, err
+	}
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+	if params == nil {
+		return CollectionConfig{}, fmt.Errorf("collection %s uses named vectors, which vector-db does not support", name)
+	}
+
+	return CollectionConfig{
+		VectorSize: params.GetSize(),
+		Distance:   distanceFromQdrant(params.GetDistance()),
+	}, nil
+}
+
+func (s *qdrantStore) Collections(ctx context.Context) ([]string, error) {
+	names, err := s.client.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *qdrantStore) DropCollection(ctx context.Context, name string) error {
+	exists, err := s.client.CollectionExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+
+	return s.client.DeleteCollection(ctx, name)
+}
+
+func (s *qdrantStore) Count(ctx context.Context, collection string, filter *Filter) (uint64, error) {
+	return s.client.Count(ctx, &qdrant.CountPoints{
+		CollectionName: collection,
+		Filter:         qdrantFilter(filter),
+		Exact:          qdrant.PtrOf(true),
+	})
+}
+
+func (s *qdrantStore) Upsert(ctx context.Context, collection string, points []Point) error {
+	qpoints := make([]*qdrant.PointStruct, 0, len(points))
+	for _, p := range points {
+		payload, err := qdrant.TryValueMap(p.Payload)
+		if err != nil {
+			return fmt.Errorf("point %s: %w", p.ID, err)
+		}
+		qpoints = append(qpoints, &qdrant.PointStruct{
+			Id:      qdrantID(p.ID),
+			Vectors: qdrant.NewVectors(p.Vector...),
+			Payload: payload,
+		})
+	}
+
+	_, err := s.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: collection,
+		Points:         qpoints,
+	})
+
+	return err
+}
+
+func (s *qdrantStore) Search(ctx context.Context, collection string, query SearchQuery) ([]ScoredPoint, error) {
+	results, err := s.client.GetPointsClient().Search(ctx, &qdrant.SearchPoints{
+		CollectionName: collection,
+		Vector:         query.Vector,
+		Limit:          query.Limit,
+		Filter:         qdrantFilter(query.Filter),
+		ScoreThreshold: query.ScoreThreshold,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scored := make([]ScoredPoint, 0, len(results.Result))
+	for _, result := range results.Result {
+		scored = append(scored, ScoredPoint{
+			Point: Point{
+				ID:      pointIDString(result.GetId()),
+				Payload: payloadToMap(result.GetPayload()),
+			},
+			Score: result.GetScore(),
+		})
+	}
+
+	return scored, nil
+}
+
+func (s *qdrantStore) Delete(ctx context.Context, collection string, ids []string) error {
+	_, err := s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: collection,
+		Points:         qdrant.NewPointsSelector(qdrantIDs(ids)...),
+	})
+
+	return err
+}
+
+func (s *qdrantStore) Get(ctx context.Context, collection string, ids []string) ([]Point, error) {
+	results, err := s.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: collection,
+		Ids:            qdrantIDs(ids),
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(results))
+	for _, result := range results {
+		points = append(points, Point{
+			ID:      pointIDString(result.GetId()),
+			Vector:  denseVector(result.GetVectors().GetVector()),
+			Payload: payloadToMap(result.GetPayload()),
+		})
+	}
+
+	return points, nil
+}
+
+// scrollPage is how many points Scroll asks Qdrant for at a time.
+const scrollPage = 256
+
+func (s *qdrantStore) Scroll(ctx context.Context, collection string, filter *Filter, withVectors bool) ([]Point, error) {
+	var points []Point
+	var offset *qdrant.PointId
+	for {
+		results, next, err := s.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: collection,
+			Filter:         qdrantFilter(filter),
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(scrollPage)),
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(withVectors),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			points = append(points, Point{
+				ID:      pointIDString(result.GetId()),
+				Vector:  denseVector(result.GetVectors().GetVector()),
+				Payload: payloadToMap(result.GetPayload()),
+			})
+		}
+		if next == nil {
+			break
+		}
+		offset = next
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].ID < points[j].ID })
+
+	return points, nil
+}
+
+func (s *qdrantStore) Close() error {
+	return s.client.Close()
+}
+
+// denseVector reads a returned vector from either the newer dense field or the older flat data field.
+func denseVector(v *qdrant.VectorOutput) []float32 {
+	if dense := v.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+
+	return v.GetData()
+}
+
+// qdrantID treats IDs that parse as unsigned integers as numeric IDs and everything else as a UUID.
+func qdrantID(id string) *qdrant.PointId {
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return qdrant.NewIDNum(n)
+	}
+
+	return qdrant.NewID(id)
+}
+
+func qdrantIDs(ids []string) []*qdrant.PointId {
+	result := make([]*qdrant.PointId, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, qdrantID(id))
+	}
+
+	return result
+}
+
+func pointIDString(id *qdrant.PointId) string {
+	if uuid := id.GetUuid(); uuid != "" {
+		return uuid
+	}
+
+	return strconv.FormatUint(id.GetNum(), 10)
+}
+
+func payloadToMap(payload map[string]*qdrant.Value) map[string]any {
+	result := make(map[string]any, len(payload))
+	for key, value := range payload {
+		result[key] = valueToAny(value)
+	}
+
+	return result
+}
+
+// valueToAny converts a Qdrant payload value back into plain Go values that encoding/json understands.
+func valueToAny(v *qdrant.Value) any {
+	switch kind := v.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return kind.StringValue
+	case *qdrant.Value_IntegerValue:
+		return kind.IntegerValue
+	case *qdrant.Value_DoubleValue:
+		return kind.DoubleValue
+	case *qdrant.Value_BoolValue:
+		return kind.BoolValue
+	case *qdrant.Value_StructValue:
+		return payloadToMap(kind.StructValue.GetFields())
+	case *qdrant.Value_ListValue:
+		list := make([]any, 0, len(kind.ListValue.GetValues()))
+		for _, item := range kind.ListValue.GetValues() {
+			list = append(list, valueToAny(item))
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+func distanceFromQdrant(d qdrant.Distance) Distance {
+	switch d {
+	case qdrant.Distance_Dot:
+		return DistanceDot
+	case qdrant.Distance_Euclid:
+		return DistanceEuclid
+	default:
+		return DistanceCosine
+	}
+}
+
+func qdrantDistance(d Distance) qdrant.Distance {
+	switch d {
+	case DistanceDot:
+		return qdrant.Distance_Dot
+	case DistanceEuclid:
+		return qdrant.Distance_Euclid
+	default:
+		return qdrant.Distance_Cosine
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Documents are read from a file one row at a time so ingestion can stream them in batches.
+// A row that can't become a document is reported as a *RowError and skipped, reading carries on with the
+// next row. Any other error means the file itself can't be read any further.
+
+const (
+	InputAuto  = "auto"
+	InputLines = "lines"
+	InputJSONL = "jsonl"
+	InputCSV   = "csv"
+)
+
+type documentReader interface {
+	// Next returns the next document, a *RowError for a bad row, or io.EOF after the last row.
+	Next() (Document, error)
+}
+
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("row %d: %v", e.Row, e.Err) }
+func (e *RowError) Unwrap() error { return e.Err }
+
+// detectInputFormat picks a format from the file extension, anything that isn't JSONL or CSV is plain lines.
+func detectInputFormat(path, format string) (string, error) {
+	switch format {
+	case InputLines, InputJSONL, InputCSV:
+		return format, nil
+	case InputAuto, "":
+	default:
+		return "", fmt.Errorf("unknown input format %q (want %s, %s, %s or %s)", format, InputAuto, InputLines, InputJSONL, InputCSV)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return InputJSONL, nil
+	case ".csv":
+		return InputCSV, nil
+	default:
+		return InputLines, nil
+	}
+}
+
+func newDocumentReader(r io.Reader, format, textField string, schema Schema) (documentReader, error) {
+	br, err := skipBOM(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case InputJSONL:
+		return &jsonlReader{r: br, textField: textField, schema: schema}, nil
+	case InputCSV:
+		return newCSVReader(br, textField, schema)
+	default:
+		return &lineReader{r: br}, nil
+	}
+}
+
+// skipBOM drops the UTF-8 byte order mark spreadsheet programs put at the start of the files they export,
+// which would otherwise end up in the first CSV column name or the first line.
+func skipBOM(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReader(r)
+	start, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(start, []byte("\ufeff")) {
+		br.Discard(3)
+	}
+
+	return br, nil
+}
+
+// lineReader makes a document of every non-blank line, with its line number as payload.
+type lineReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (lr *lineReader) Next() (Document, error) {
+	for {
+		line, err := lr.r.ReadString('\n')
+		if line == "" && err != nil {
+			return Document{}, err
+		}
+		lr.line++
+		if text := strings.TrimSpace(line); text != "" {
+			return Document{Text: text, Payload: map[string]any{"line": lr.line}}, nil
+		}
+	}
+}
+
+// jsonlReader reads one JSON object per line. textField holds the text, every other field is payload.
+type jsonlReader struct {
+	r         *bufio.Reader
+	textField string
+	schema    Schema
+	line      int
+}
+
+func (jr *jsonlReader) Next() (Document, error) {
+	for {
+		line, err := jr.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return Document{}, err
+		}
+		jr.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var fields map[string]any
+		if err := dec.Decode(&fields); err != nil {
+			return Document{}, &RowError{Row: jr.line, Err: fmt.Errorf("not a JSON object: %w", err)}
+		}
+		if dec.More() {
+			return Document{}, &RowError{Row: jr.line, Err: errors.New("more than one JSON value on the line")}
+		}
+
+		doc, err := newRowDocument(normalizePayload(fields), jr.textField, jr.schema)
+		if err != nil {
+			return Document{}, &RowError{Row: jr.line, Err: err}
+		}
+		return doc, nil
+	}
+}
+
+// csvReader reads a CSV file whose first row names the columns. Values are strings unless the schema types them.
+type csvReader struct {
+	r         *csv.Reader
+	header    []string
+	textField string
+	schema    Schema
+}
+
+func newCSVReader(r io.Reader, textField string, schema Schema) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty, it needs a header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("CSV header column %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("CSV header names column %q twice", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+	if !seen[textField] {
+		return nil, fmt.Errorf("CSV header has no %q column for the text, pick one with -text-field", textField)
+	}
+
+	return &csvReader{r: cr, header: header, textField: textField, schema: schema}, nil
+}
+
+func (cr *csvReader) Next() (Document, error) {
+	record, err := cr.r.Read()
+	if err == io.EOF {
+		return Document{}, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Document{}, &RowError{Row: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return Document{}, err
+	}
+
+	row, _ := cr.r.FieldPos(0)
+	fields := make(map[string]any, len(record))
+	for i, value := range record {
+		fields[cr.header[i]] = value
+	}
+	doc, err := newRowDocument(fields, cr.textField, cr.schema)
+	if err != nil {
+		return Document{}, &RowError{Row: row, Err: err}
+	}
+
+	return doc, nil
+}
+
+// newRowDocument takes the text out of fields, checks the rest against the schema and keeps it as payload.
+func newRowDocument(fields map[string]any, textField string, schema Schema) (Document, error) {
+	value, ok := fields[textField]
+	if !ok {
+		return Document{}, fmt.Errorf("no %q field", textField)
+	}
+	text, ok := value.(string)
+	if !ok {
+		return Document{}, fmt.Errorf("field %q is %s, not a string", textField, typeName(value))
+	}
+	if strings.TrimSpace(text) == "" {
+		return Document{}, fmt.Errorf("field %q is empty", textField)
+	}
+	delete(fields, textField)
+
+	for _, reserved := range []string{payloadText, payloadSource, payloadContentHash} {
+		if _, ok := fields[reserved]; ok {
+			return Document{}, fmt.Errorf("field %q is reserved for ingestion, rename it", reserved)
+		}
+	}
+	if err := schema.apply(fields); err != nil {
+		return Document{}, err
+	}
+
+	return Document{Text: text, Payload: fields}, nil
+}
+
+// Schema lists payload fields and the type each must have.
+type Schema []SchemaField
+
+type SchemaField struct {
+	Name     string
+	Type     string
+	Optional bool
+}
+
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+)
+
+// parseSchema reads a comma separated list of name:type fields, e.g. "genre:string,year:int,rating:float?".
+// A trailing ? makes a field optional, every other field has to be present in every row.
+func parseSchema(spec string) (Schema, error) {
+	var schema Schema
+	if strings.TrimSpace(spec) == "" {
+		return schema, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		name, typ, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid schema field %q, want name:type", part)
+		}
+		typ, optional := strings.CutSuffix(typ, "?")
+		switch typ {
+		case TypeString, TypeInt, TypeFloat, TypeBool:
+		default:
+			return nil, fmt.Errorf("schema field %s has unknown type %q (want string, int, float or bool)", name, typ)
+		}
+		schema = append(schema, SchemaField{Name: name, Type: typ, Optional: optional})
+	}
+
+	return schema, nil
+}
+
+// apply checks fields against the schema, converting CSV strings to the declared type along the way.
+// Fields the schema doesn't mention are kept as they are.
+func (s Schema) apply(fields map[string]any) error {
+	for _, f := range s {
+		value, ok := fields[f.Name]
+		if !ok || value == nil || value == "" {
+			if !f.Optional {
+				return fmt.Errorf("required field %q is missing", f.Name)
+			}
+			delete(fields, f.Name)
+			continue
+		}
+
+		converted, err := convertField(value, f.Type)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", f.Name, err)
+		}
+		fields[f.Name] = converted
+	}
+
+	return nil
+}
+
+func convertField(value any, typ string) (any, error) {
+	s, isString := value.(string)
+	switch typ {
+	case TypeString:
+		if isString {
+			return s, nil
+		}
+	case TypeInt:
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return i, nil
+			}
+		}
+	case TypeFloat:
+		switch v := value.(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, nil
+			}
+		}
+	case TypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("can't use %s %v as %s", typeName(value), value, typ)
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case int64:
+		return "an integer"
+	case float64:
+		return "a float"
+	case bool:
+		return "a bool"
+	case nil:
+		return "null"
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	default:
+		return fmt.Sprintf("a %T", v)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll reads every document of input and the rows it skipped.
+func readAll(t *testing.T, input, format, textField string, schema Schema) ([]Document, []int) {
+	t.Helper()
+
+	r, err := newDocumentReader(strings.NewReader(input), format, textField, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []Document
+	var badRows []int
+	for {
+		doc, err := r.Next()
+		if err == io.EOF {
+			return docs, badRows
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			badRows = append(badRows, rowErr.Row)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func mustParseSchema(t *testing.T, spec string) Schema {
+	t.Helper()
+
+	schema, err := parseSchema(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return schema
+}
+
+func TestDetectInputFormat(t *testing.T) {
+	tests := []struct {
+		path, format, want string
+	}{
+		{"plays.jsonl", InputAuto, InputJSONL},
+		{"plays.NDJSON", "", InputJSONL},
+		{"plays.csv", InputAuto, InputCSV},
+		{"macbeth.txt", InputAuto, InputLines},
+		{"macbeth", InputAuto, InputLines},
+		{"plays.csv", InputLines, InputLines},
+		{"plays.txt", InputJSONL, InputJSONL},
+	}
+	for _, tt := range tests {
+		if got, err := detectInputFormat(tt.path, tt.format); err != nil || got != tt.want {
+			t.Errorf("detectInputFormat(%q, %q) = %q, %v, want %q", tt.path, tt.format, got, err, tt.want)
+		}
+	}
+	if _, err := detectInputFormat("plays.csv", "xml"); err == nil {
+		t.Error("an unknown format was accepted")
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	docs, bad := readAll(t, "\ufeffThunder.\n\n  Enter Witches.  \r\nWhen shall we three meet againe", InputLines, "", nil)
+	want := []Document{
+		{Text: "Thunder.", Payload: map[string]any{"line": 1}},
+		{Text: "Enter Witches.", Payload: map[string]any{"line": 3}},
+		{Text: "When shall we three meet againe", Payload: map[string]any{"line": 4}},
+	}
+	if !reflect.DeepEqual(docs, want) || len(bad) != 0 {
+		t.Errorf("got %v and bad rows %v, want %v", docs, bad, want)
+	}
+}
+
+func TestJSONLReader(t *testing.T) {
+	input := strings.Join([]string{
+		`{"body": "Thunder.", "act": 1, "scene": {"n": 1}, "tags": ["storm"]}`,
+		``,
+		`{"body": "Enter Witches.", "rating": 4.5, "draft": true}`,
+		`not json`,
+		`{"body": "two"} {"body": "values"}`,
+		`{"text": "no body"}`,
+		`{"body": 7}`,
+		`{"body": "  "}`,
+		`{"body": "reserved", "source": "elsewhere"}`,
+		`{"body": "reserved", "content_hash": "x"}`,
+		`["body"]`,
+		`{"body": "Exeunt."}`,
+	}, "\n")
+	docs, bad := readAll(t, input, InputJSONL, "body", nil)
+
+	want := []Document{
+		{Text: "Thunder.", Payload: map[string]any{"act": int64(1), "scene": map[string]any{"n": int64(1)}, "tags": []any{"storm"}}},
+		{Text: "Enter Witches.", Payload: map[string]any{"rating": 4.5, "draft": true}},
+		{Text: "Exeunt.", Payload: map[string]any{}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("got %v, want %v", docs, want)
+	}
+	if fmt.Sprint(bad) != "[4 5 6 7 8 9 10 11]" {
+		t.Errorf("bad rows %v, want 4 to 11", bad)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	input := "\ufefftitle,year,rating,notes\n" +
+		"Macbeth,1606,4.5,\"short, \"\"bloody\"\"\"\n" +
+		"Hamlet,1600,,\"long\nvery long\"\n" +
+		"King Lear,soon,4,\n" +
+		"Othello,1603\n" +
+		",1611,3,\n" +
+		"The Tempest, 1611 ,3,\n"
+	schema := mustParseSchema(t, "year:int,rating:float?")
+	docs, bad := readAll(t, input, InputCSV, "title", schema)
+
+	want := []Document{
+		{Text: "Macbeth", Payload: map[string]any{"year": int64(1606), "rating": 4.5, "notes": `short, "bloody"`}},
+		{Text: "Hamlet", Payload: map[string]any{"year": int64(1600), "notes": "long\nvery long"}},
+		{Text: "The Tempest", Payload: map[string]any{"year": int64(1611), "rating": 3.0, "notes": ""}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("got %v, want %v", docs, want)
+	}
+	// Rows count file lines, so the quoted line break in Hamlet's notes pushes King Lear to line 5.
+	if fmt.Sprint(bad) != "[5 6 7]" {
+		t.Errorf("bad rows %v, want 5, 6 and 7", bad)
+	}
+}
+
+func TestCSVHeader(t *testing.T) {
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"\ufefftitle\nMacbeth\n", true},
+		{" title , year\nMacbeth,1606\n", true},
+		{"", false},
+		{"\ufeff", false},
+		{"name\nMacbeth\n", false},
+		{"title,,year\n", false},
+		{"title,year,title\n", false},
+		{"title,\"year\n", false},
+	}
+	for _, tt := range tests {
+		_, err := newDocumentReader(strings.NewReader(tt.input), InputCSV, "title", nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("header %q: got error %v, want an error %t", tt.input, err, !tt.ok)
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	schema, err := parseSchema(" genre:string, year:int ,rating:float?,draft:bool?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Schema{
+		{Name: "genre", Type: TypeString},
+		{Name: "year", Type: TypeInt},
+		{Name: "rating", Type: TypeFloat, Optional: true},
+		{Name: "draft", Type: TypeBool, Optional: true},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("got %+v, want %+v", schema, want)
+	}
+	if schema, err := parseSchema("  "); err != nil || len(schema) != 0 {
+		t.Errorf("an empty spec gave %v, %v", schema, err)
+	}
+
+	for _, spec := range []string{"genre", ":string", "genre:text", "year:int??", "genre:string,"} {
+		if _, err := parseSchema(spec); err == nil {
+			t.Errorf("parseSchema(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestSchemaApply(t *testing.T) {
+	schema := mustParseSchema(t, "genre:string,year:int,rating:float?,draft:bool?")
+	tests := []struct {
+		name   string
+		fields map[string]any
+		want   map[string]any
+	}{
+		{"JSON values",
+			map[string]any{"genre": "tragedy", "year": int64(1606), "rating": int64(4), "draft": false, "extra": []any{}},
+			map[string]any{"genre": "tragedy", "year": int64(1606), "rating": 4.0, "draft": false, "extra": []any{}}},
+		{"CSV strings",
+			map[string]any{"genre": "tragedy", "year": " 1606", "rating": "4.5", "draft": "true"},
+			map[string]any{"genre": "tragedy", "year": int64(1606), "rating": 4.5, "draft": true}},
+		{"optional fields left out",
+			map[string]any{"genre": "tragedy", "year": int64(1606), "rating": "", "draft": nil},
+			map[string]any{"genre": "tragedy", "year": int64(1606)}},
+		{"required field missing", map[string]any{"genre": "tragedy"}, nil},
+		{"required field empty", map[string]any{"genre": "", "year": int64(1606)}, nil},
+		{"float for an int", map[string]any{"genre": "tragedy", "year": 1606.5}, nil},
+		{"string for an int", map[string]any{"genre": "tragedy", "year": "soon"}, nil},
+		{"int for a string", map[string]any{"genre": int64(1), "year": int64(1606)}, nil},
+		{"bad bool", map[string]any{"genre": "tragedy", "year": int64(1606), "draft": "maybe"}, nil},
+	}
+	for _, tt := range tests {
+		err := schema.apply(tt.fields)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", tt.name, tt.fields)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(tt.fields, tt.want) {
+			t.Errorf("%s: got %v, %v, want %v", tt.name, tt.fields, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// VectorStore is everything the application needs from a vector database.
+// qdrantStore talks to a Qdrant server, memoryStore keeps everything in process and scans every vector on search.
+type VectorStore interface {
+	CreateCollection(ctx context.Context, name string, config CollectionConfig) error
+	// Collection returns the config of an existing collection or ErrCollectionNotFound.
+	Collection(ctx context.Context, name string) (CollectionConfig, error)
+	// Collections returns the names of every collection, sorted.
+	Collections(ctx context.Context) ([]string, error)
+	DropCollection(ctx context.Context, name string) error
+	Upsert(ctx context.Context, collection string, points []Point) error
+	Search(ctx context.Context, collection string, query SearchQuery) ([]ScoredPoint, error)
+	Delete(ctx context.Context, collection string, ids []string) error
+	Get(ctx context.Context, collection string, ids []string) ([]Point, error)
+	// Scroll returns every point that passes filter, ordered by ID, leaving vectors out unless withVectors is set.
+	Scroll(ctx context.Context, collection string, filter *Filter, withVectors bool) ([]Point, error)
+	// Count returns the number of points that pass filter, a nil filter counts them all.
+	Count(ctx context.Context, collection string, filter *Filter) (uint64, error)
+	// Close releases connections and files, the store can't be used afterwards.
+	Close() error
+}
+
+var (
+	ErrCollectionExists   = errors.New("collection already exists")
+	ErrCollectionNotFound = errors.New("collection not found")
+)
+
+type Distance int
+
+const (
+	DistanceCosine Distance = iota
+	DistanceDot
+	DistanceEuclid
+)
+
+func (d Distance) String() string {
+	switch d {
+	case DistanceCosine:
+		return "cosine"
+	case DistanceDot:
+		return "dot"
+	case DistanceEuclid:
+		return "euclid"
+	default:
+		return fmt.Sprintf("Distance(%d)", int(d))
+	}
+}
+
+func parseDistance(name string) (Distance, error) {
+	for _, d := range []Distance{DistanceCosine, DistanceDot, DistanceEuclid} {
+		if d.String() == name {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown distance %q (want cosine, dot or euclid)", name)
+}
+
+type CollectionConfig struct {
+	VectorSize uint64   `json:"vector_size"`
+	Distance   Distance `json:"distance"`
+}
+
+// Point is a vector and its payload.
+// IDs are strings so both Qdrant's numeric IDs ("3") and UUIDs fit, qdrantStore converts between the two.
+type Point struct {
+	ID      string         `json:"id"`
+	Vector  []float32      `json:"vector"`
+	Payload map[string]any `json:"payload,omitempty"`
+}
+
+// normalizePayload turns the json.Numbers a decoded payload holds back into int64 or float64,
+// matching what the Qdrant store returns.
+func normalizePayload(payload map[string]any) map[string]any {
+	for key, value := range payload {
+		payload[key] = normalizeValue(value)
+	}
+
+	return payload
+}
+
+func normalizeValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		return normalizePayload(v)
+	case []any:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// SearchQuery asks for the Limit points closest to Vector among those that pass Filter when it is set.
+// ScoreThreshold, when set, drops hits scoring worse than it: below it for cosine and dot, above it for euclid.
+type SearchQuery struct {
+	Vector         []float32
+	Limit          uint64
+	Filter         *Filter
+	ScoreThreshold *float32
+}
+
+// ScoredPoint is a search hit. For cosine and dot higher scores are closer, for euclid the score is the distance.
+type ScoredPoint struct {
+	Point
+	Score float32
+}
+
+const (
+	StoreQdrant = "qdrant"
+	StoreMemory = "memory"
+	StoreDisk   = "disk"
+)
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// IVF is an inverted file index: k-means splits the stored vectors into lists around centroids, and a search
+// only scores the vectors in the nprobe lists whose centroids are most similar to the query. With L lists of
+// roughly n/L vectors each a query costs about L + nprobe*n/L similarity computations instead of n. Raising
+// nprobe trades speed back for recall, nprobe = L is an exact search.
+
+type IVFOptions struct {
+	// Lists is the number of centroids, sqrt(n) is the usual starting point.
+	Lists int
+	// NProbe is the number of lists searched per query.
+	NProbe int
+	// Iterations bounds the k-means rounds in Train, it stops early once no vector changes list.
+	Iterations int
+	// Metric is cosine, dot or euclid.
+	Metric string
+	Seed   int64
+}
+
+type Neighbour struct {
+	ID    int
+	Score float64
+}
+
+type IVF struct {
+	opts       IVFOptions
+	similarity func(a, b []float64) float64
+	rng        *rand.Rand
+	// dim is set by the first Add, every later vector must match it.
+	dim     int
+	vectors map[int][]float64
+	// centroids is empty until the first Train, until then every search is exact.
+	centroids [][]float64
+	lists     [][]int
+	listOf    map[int]int
+	// pending holds vectors added while untrained, they are scored on every search.
+	pending map[int]bool
+	// trainedQuality is the mean similarity of vectors to their centroid right after training.
+	trainedQuality float64
+}
+
+func newIVF(opts IVFOptions) (*IVF, error) {
+	var similarity func(a, b []float64) float64
+	switch opts.Metric {
+	case "cosine":
+		similarity = cosineSimilarity
+	case "dot":
+		similarity = dotProduct
+	case "euclid":
+		// Negated so that, like the others, a higher score means closer.
+		similarity = func(a, b []float64) float64 { return -magnitude(subtract(a, b)) }
+	default:
+		return nil, fmt.Errorf("unknown metric %q (want cosine, dot or euclid)", opts.Metric)
+	}
+	if opts.Lists < 1 || opts.NProbe < 1 {
+		return nil, fmt.Errorf("lists and nprobe must be positive, got %d and %d", opts.Lists, opts.NProbe)
+	}
+
+	return &IVF{
+		opts:       opts,
+		similarity: similarity,
+		rng:        rand.New(rand.NewSource(opts.Seed)),
+		vectors:    make(map[int][]float64),
+		listOf:     make(map[int]int),
+		pending:    make(map[int]bool),
+	}, nil
+}
+
+func (ivf *IVF) Len() int { return len(ivf.vectors) }
+
+// Add stores vec under id, replacing any vector already stored there, and files it under its nearest centroid.
+func (ivf *IVF) Add(id int, vec []float64) error {
+	if ivf.dim == 0 {
+		ivf.dim = len(vec)
+	}
+	if len(vec) != ivf.dim || len(vec) == 0 {
+		return fmt.Errorf("vector %d has %d dimensions, the index holds %d", id, len(vec), ivf.dim)
+	}
+
+	ivf.Remove(id)
+	ivf.vectors[id] = vec
+	if len(ivf.centroids) == 0 {
+		ivf.pending[id] = true
+		return nil
+	}
+
+	list := ivf.nearestCentroid(vec)
+	ivf.lists[list] = append(ivf.lists[list], id)
+	ivf.listOf[id] = list
+
+	return nil
+}
+
+func (ivf *IVF) Remove(id int) {
+	if _, ok := ivf.vectors[id]; !ok {
+		return
+	}
+	delete(ivf.vectors, id)
+	delete(ivf.pending, id)
+
+	list, ok := ivf.listOf[id]
+	if !ok {
+		return
+	}
+	delete(ivf.listOf, id)
+	ids := ivf.lists[list]
+	for i, other := range ids {
+		if other == id {
+			ids[i] = ids[len(ids)-1]
+			ivf.lists[list] = ids[:len(ids)-1]
+			break
+		}
+	}
+}
+
+// Train runs k-means over every stored vector and rebuilds the lists. Call it again to retrain once the data
+// has drifted away from the centroids, see Drift.
+func (ivf *IVF) Train() {
+	ids := ivf.sortedIDs()
+	if len(ids) == 0 {
+		return
+	}
+	k := min(ivf.opts.Lists, len(ids))
+
+	ivf.centroids = ivf.seedCentroids(ids, k)
+	assignment := make(map[int]int, len(ids))
+	for iter := 0; iter < max(1, ivf.opts.Iterations); iter++ {
+		changed := 0
+		for _, id := range ids {
+			list := ivf.nearestCentroid(ivf.vectors[id])
+			if old, ok := assignment[id]; !ok || old != list {
+				changed++
+			}
+			assignment[id] = list
+		}
+		ivf.updateCentroids(ids, assignment)
+		if changed == 0 {
+			break
+		}
+	}
+
+	ivf.lists = make([][]int, len(ivf.centroids))
+	clear(ivf.listOf)
+	clear(ivf.pending)
+	for _, id := range ids {
+		list := ivf.nearestCentroid(ivf.vectors[id])
+		ivf.lists[list] = append(ivf.lists[list], id)
+		ivf.listOf[id] = list
+	}
+	ivf.trainedQuality = ivf.quality()
+}
+
+// seedCentroids picks k starting centroids with k-means++: each next one is a stored vector chosen with
+// probability proportional to its squared distance from the nearest centroid picked so far.
+func (ivf *IVF) seedCentroids(ids []int, k int) [][]float64 {
+	first := ivf.vectors[ids[ivf.rng.Intn(len(ids))]]
+	centroids := [][]float64{append([]float64(nil), first...)}
+
+	nearest := make([]float64, len(ids))
+	for i, id := range ids {
+		nearest[i] = squaredDistance(ivf.vectors[id], centroids[0])
+	}
+	for len(centroids) < k {
+		var total float64
+		for _, d := range nearest {
+			total += d
+		}
+		pick := 0
+		if total > 0 {
+			target := ivf.rng.Float64() * total
+			for i, d := range nearest {
+				target -= d
+				if target <= 0 {
+					pick = i
+					break
+				}
+			}
+		} else {
+			pick = ivf.rng.Intn(len(ids))
+		}
+
+		c := append([]float64(nil), ivf.vectors[ids[pick]]...)
+		centroids = append(centroids, c)
+		for i, id := range ids {
+			nearest[i] = math.Min(nearest[i], squaredDistance(ivf.vectors[id], c))
+		}
+	}
+
+	return centroids
+}
+
+// updateCentroids moves every centroid to the mean of its vectors. A centroid that lost all of its vectors is
+// moved onto a random vector so the number of lists stays the same.
+func (ivf *IVF) updateCentroids(ids []int, assignment map[int]int) {
+	counts := make([]int, len(ivf.centroids))
+	for _, id := range ids {
+		counts[assignment[id]]++
+	}
+	for i, c := range ivf.centroids {
+		if counts[i] > 0 {
+			clear(c)
+		}
+	}
+	for _, id := range ids {
+		c := ivf.centroids[assignment[id]]
+		for j, x := range ivf.vectors[id] {
+			c[j] += x
+		}
+	}
+
+	for i, c := range ivf.centroids {
+		if counts[i] == 0 {
+			copy(c, ivf.vectors[ids[ivf.rng.Intn(len(ids))]])
+			continue
+		}
+		for j := range c {
+			c[j] /= float64(counts[i])
+		}
+	}
+}
+
+func (ivf *IVF) nearestCentroid(vec []float64) int {
+	best, bestScore := 0, math.Inf(-1)
+	for i, c := range ivf.centroids {
+		if score := ivf.similarity(vec, c); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	return best
+}
+
+// Search returns the k stored vectors most similar to query among the nprobe nearest lists, best first.
+// nprobe <= 0 uses the configured NProbe.
+func (ivf *IVF) Search(query []float64, k, nprobe int) []Neighbour {
+	if nprobe <= 0 {
+		nprobe = ivf.opts.NProbe
+	}
+
+	type scoredList struct {
+		list  int
+		score float64
+	}
+	probes := make([]scoredList, len(ivf.centroids))
+	for i, c := range ivf.centroids {
+		probes[i] = scoredList{list: i, score: ivf.similarity(query, c)}
+	}
+	sort.Slice(probes, func(i, j int) bool { return probes[i].score > probes[j].score })
+
+	var candidates []int
+	for _, p := range probes[:min(nprobe, len(probes))] {
+		candidates = append(candidates, ivf.lists[p.list]...)
+	}
+	for id := range ivf.pending {
+		candidates = append(candidates, id)
+	}
+
+	return ivf.rank(query, candidates, k)
+}
+
+// exactSearch scores every stored vector, it is what Search approximates.
+func (ivf *IVF) exactSearch(query []float64, k int) []Neighbour {
+	return ivf.rank(query, ivf.sortedIDs(), k)
+}
+
+func (ivf *IVF) rank(query []float64, ids []int, k int) []Neighbour {
+	neighbours := make([]Neighbour, 0, len(ids))
+	for _, id := range ids {
+		neighbours = append(neighbours, Neighbour{ID: id, Score: ivf.similarity(query, ivf.vectors[id])})
+	}
+	sort.Slice(neighbours, func(i, j int) bool {
+		if neighbours[i].Score != neighbours[j].Score {
+			return neighbours[i].Score > neighbours[j].Score
+		}
+		return neighbours[i].ID < neighbours[j].ID
+	})
+
+	return neighbours[:min(k, len(neighbours))]
+}
+
+// quality is the mean similarity of every listed vector to its centroid.
+func (ivf *IVF) quality() float64 {
+	if len(ivf.listOf) == 0 {
+		return 0
+	}
+	var sum float64
+	for id, list := range ivf.listOf {
+		sum += ivf.similarity(ivf.vectors[id], ivf.centroids[list])
+	}
+
+	return sum / float64(len(ivf.listOf))
+}
+
+// Drift is how much worse vectors fit their centroids now than right after training, as a fraction of the
+// fit at training time. Vectors added from a shifted distribution land far from every centroid and pile into
+// a few lists, which shows up here, so retrain once it climbs past a tenth or so.
+func (ivf *IVF) Drift() float64 {
+	if len(ivf.centroids) == 0 || ivf.trainedQuality == 0 {
+		return 0
+	}
+
+	return (ivf.trainedQuality - ivf.quality()) / math.Abs(ivf.trainedQuality)
+}
+
+// Imbalance is the size of the largest list over the mean list size, 1 is perfectly even.
+func (ivf *IVF) Imbalance() float64 {
+	if len(ivf.lists) == 0 || len(ivf.listOf) == 0 {
+		return 0
+	}
+	largest := 0
+	for _, ids := range ivf.lists {
+		largest = max(largest, len(ids))
+	}
+
+	return float64(largest) / (float64(len(ivf.listOf)) / float64(len(ivf.lists)))
+}
+
+func (ivf *IVF) sortedIDs() []int {
+	ids := make([]int, 0, len(ivf.vectors))
+	for id := range ivf.vectors {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+func squaredDistance(a, b []float64) float64 {
+	d := magnitude(subtract(a, b))
+	return d * d
+}
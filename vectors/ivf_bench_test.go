@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// BenchmarkIVF searches an index over synthetic vectors drawn from gaussian clusters, for a range of nprobe
+// values, and reports the recall@k of each against an exact scan:
+//
+//	go test -run '^$' -bench IVF
+//
+// It then adds half as many vectors again from clusters the index has never seen and reports the recall,
+// drift and imbalance before and after retraining.
+func BenchmarkIVF(b *testing.B) {
+	const (
+		n       = 20000
+		dim     = 64
+		k       = 10
+		queries = 200
+	)
+	opts := IVFOptions{Lists: int(math.Sqrt(n)), NProbe: 8, Iterations: 20, Metric: "cosine", Seed: 1}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	clusters := opts.Lists / 2
+
+	ivf := newBenchIVF(b, opts, clusteredVectors(rng, n, dim, clusters, 0))
+	qs := clusteredVectors(rng, queries, dim, clusters, 0)
+	exact := searchAll(qs, func(q []float64) []Neighbour { return ivf.exactSearch(q, k) })
+
+	b.Run("exact", func(b *testing.B) {
+		for i := 0; b.Loop(); i++ {
+			ivf.exactSearch(qs[i%len(qs)], k)
+		}
+	})
+	for _, nprobe := range []int{1, 2, 4, 8, 16, 32} {
+		approx := searchAll(qs, func(q []float64) []Neighbour { return ivf.Search(q, k, nprobe) })
+		b.Run(fmt.Sprintf("nprobe=%d", nprobe), func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				ivf.Search(qs[i%len(qs)], k, nprobe)
+			}
+			b.ReportMetric(recall(exact, approx), fmt.Sprintf("recall@%d", k))
+		})
+	}
+
+	// New data from a shifted distribution gets filed under whichever old centroids happen to be nearest.
+	for i, v := range clusteredVectors(rng, n/2, dim, clusters, 3) {
+		if err := ivf.Add(n+i, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+	drifted := clusteredVectors(rng, queries, dim, clusters, 3)
+	run := func(name string) {
+		exact := searchAll(drifted, func(q []float64) []Neighbour { return ivf.exactSearch(q, k) })
+		approx := searchAll(drifted, func(q []float64) []Neighbour { return ivf.Search(q, k, 0) })
+		b.Run(name, func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				ivf.Search(drifted[i%len(drifted)], k, 0)
+			}
+			b.ReportMetric(recall(exact, approx), fmt.Sprintf("recall@%d", k))
+			b.ReportMetric(ivf.Drift(), "drift")
+			b.ReportMetric(ivf.Imbalance(), "imbalance")
+		})
+	}
+	run("drifted")
+	ivf.Train()
+	run("retrained")
+}
+
+// newBenchIVF adds vectors under their positions and trains the index on them.
+func newBenchIVF(tb testing.TB, opts IVFOptions, vectors [][]float64) *IVF {
+	tb.Helper()
+
+	ivf, err := newIVF(opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i, v := range vectors {
+		if err := ivf.Add(i, v); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	ivf.Train()
+
+	return ivf
+}
+
+// clusteredVectors draws n vectors around the given number of random cluster centres.
+// The centres depend only on shift, so stored vectors and queries drawn with the same shift share clusters.
+func clusteredVectors(rng *rand.Rand, n, dim, clusters int, shift int64) [][]float64 {
+	centreRng := rand.New(rand.NewSource(shift + 1))
+	centres := make([][]float64, clusters)
+	for i := range centres {
+		centres[i] = make([]float64, dim)
+		for j := range centres[i] {
+			centres[i][j] = centreRng.NormFloat64()
+		}
+	}
+
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		c := centres[rng.Intn(clusters)]
+		v := make([]float64, dim)
+		for j := range v {
+			v[j] = c[j] + 0.3*rng.NormFloat64()
+		}
+		vectors[i] = v
+	}
+
+	return vectors
+}
+
+func searchAll(queries [][]float64, search func([]float64) []Neighbour) [][]Neighbour {
+	results := make([][]Neighbour, len(queries))
+	for i, q := range queries {
+		results[i] = search(q)
+	}
+
+	return results
+}
+
+// recall is the mean fraction of each exact result list that the approximate search also found.
+func recall(exact, approx [][]Neighbour) float64 {
+	var total float64
+	for i := range exact {
+		if len(exact[i]) == 0 {
+			total++
+			continue
+		}
+		found := make(map[int]bool, len(approx[i]))
+		for _, nb := range approx[i] {
+			found[nb.ID] = true
+		}
+		hits := 0
+		for _, nb := range exact[i] {
+			if found[nb.ID] {
+				hits++
+			}
+		}
+		total += float64(hits) / float64(len(exact[i]))
+	}
+
+	return total / float64(max(1, len(exact)))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// axisClusters draws per vectors around 10 times each of the given unit axes, so every cluster is orthogonal to
+// the others.
+func axisClusters(rng *rand.Rand, dim, per int, axes ...int) [][]float64 {
+	var vectors [][]float64
+	for _, axis := range axes {
+		for range per {
+			v := make([]float64, dim)
+			for j := range v {
+				v[j] = 0.5 * rng.NormFloat64()
+			}
+			v[axis] += 10
+			vectors = append(vectors, v)
+		}
+	}
+
+	return vectors
+}
+
+func TestIVFAdd(t *testing.T) {
+	ivf, err := newIVF(IVFOptions{Lists: 2, NProbe: 1, Metric: "cosine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ivf.Add(1, nil); err == nil {
+		t.Error("adding an empty vector succeeded")
+	}
+	if err := ivf.Add(1, []float64{1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ivf.Add(2, []float64{1, 0}); err == nil {
+		t.Error("adding a 2 dimensional vector to a 3 dimensional index succeeded")
+	}
+	if err := ivf.Add(1, []float64{0, 1, 0}); err != nil || ivf.Len() != 1 {
+		t.Errorf("replacing vector 1: %v, %d vectors", err, ivf.Len())
+	}
+
+	// Untrained, every vector is pending and searched.
+	if err := ivf.Add(2, []float64{0, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ivf.Search([]float64{0, 1, 0.1}, 2, 1); len(got) != 2 || got[0].ID != 1 {
+		t.Errorf("untrained search: got %v, want 1 then 2", got)
+	}
+	ivf.Remove(1)
+	ivf.Remove(1)
+	if got := ivf.Search([]float64{0, 1, 0}, 2, 1); ivf.Len() != 1 || len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("search after removing 1: got %v", got)
+	}
+}
+
+// TestIVFTrain checks that training on orthogonal clusters files every cluster under its own list, with each
+// centroid at the mean of its list.
+func TestIVFTrain(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ivf := newBenchIVF(t, IVFOptions{Lists: 4, NProbe: 1, Iterations: 20, Metric: "cosine", Seed: 1}, axisClusters(rng, 8, 50, 0, 1, 2, 3))
+
+	for list, ids := range ivf.lists {
+		if len(ids) != 50 {
+			t.Errorf("list %d holds %d vectors, want one whole cluster of 50", list, len(ids))
+			continue
+		}
+		mean := make([]float64, 8)
+		for _, id := range ids {
+			if id/50 != ids[0]/50 {
+				t.Errorf("list %d mixes clusters %d and %d", list, ids[0]/50, id/50)
+			}
+			for j, x := range ivf.vectors[id] {
+				mean[j] += x / 50
+			}
+		}
+		for j := range mean {
+			if math.Abs(mean[j]-ivf.centroids[list][j]) > 1e-9 {
+				t.Fatalf("centroid %d is %v, want the mean of its list %v", list, ivf.centroids[list], mean)
+			}
+		}
+	}
+	if got := ivf.Imbalance(); got != 1 {
+		t.Errorf("Imbalance = %v, want 1 for even lists", got)
+	}
+	if got := ivf.Drift(); math.Abs(got) > 1e-9 {
+		t.Errorf("Drift = %v right after training, want 0", got)
+	}
+
+	// Vectors from the clusters keep the fit.
+	for i, v := range axisClusters(rng, 8, 10, 0, 1, 2, 3) {
+		if err := ivf.Add(1000+i, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := ivf.Drift(); math.Abs(got) > 0.01 {
+		t.Errorf("Drift = %v after adding vectors like the trained ones, want about 0", got)
+	}
+}
+
+// TestIVFRetrain adds a cluster the index has never seen, which piles into a list it doesn't fit, and retrains
+// so that each of the five clusters gets one of the five lists.
+func TestIVFRetrain(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	opts := IVFOptions{Lists: 5, NProbe: 1, Iterations: 20, Metric: "cosine", Seed: 1}
+	ivf := newBenchIVF(t, opts, axisClusters(rng, 8, 50, 0, 1, 2, 3))
+	trained := ivf.Imbalance()
+
+	for i, v := range axisClusters(rng, 8, 50, 4) {
+		if err := ivf.Add(200+i, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := ivf.Drift(); got < 0.1 {
+		t.Errorf("Drift = %.3f after adding a new cluster, want above 0.1", got)
+	}
+	if got := ivf.Imbalance(); got <= trained {
+		t.Errorf("Imbalance = %.2f after adding a new cluster, want above %.2f", got, trained)
+	}
+
+	queries := axisClusters(rng, 8, 20, 4)
+	before := recall(searchAll(queries, func(q []float64) []Neighbour { return ivf.exactSearch(q, 10) }),
+		searchAll(queries, func(q []float64) []Neighbour { return ivf.Search(q, 10, 0) }))
+
+	ivf.Train()
+	if got := ivf.Drift(); math.Abs(got) > 1e-9 {
+		t.Errorf("Drift = %v after retraining, want 0", got)
+	}
+	if got := ivf.Imbalance(); got != 1 {
+		t.Errorf("Imbalance = %.2f after retraining, want every cluster in a list of its own", got)
+	}
+	after := recall(searchAll(queries, func(q []float64) []Neighbour { return ivf.exactSearch(q, 10) }),
+		searchAll(queries, func(q []float64) []Neighbour { return ivf.Search(q, 10, 0) }))
+	if after < 0.99 || after < before {
+		t.Errorf("recall@10 of the new cluster is %.3f after retraining, %.3f before", after, before)
+	}
+}
+
+func TestIVFRecall(t *testing.T) {
+	const n, dim, k = 4000, 32, 10
+	for _, metric := range []string{"cosine", "dot", "euclid"} {
+		rng := rand.New(rand.NewSource(3))
+		opts := IVFOptions{Lists: int(math.Sqrt(n)), NProbe: 8, Iterations: 20, Metric: metric, Seed: 1}
+		ivf := newBenchIVF(t, opts, clusteredVectors(rng, n, dim, opts.Lists/2, 0))
+		queries := clusteredVectors(rng, 50, dim, opts.Lists/2, 0)
+		exact := searchAll(queries, func(q []float64) []Neighbour { return ivf.exactSearch(q, k) })
+
+		// Probing every list is an exact search.
+		all := searchAll(queries, func(q []float64) []Neighbour { return ivf.Search(q, k, opts.Lists) })
+		for i := range exact {
+			if !slices.Equal(all[i], exact[i]) {
+				t.Errorf("%s: searching every list found %v, want %v", metric, all[i], exact[i])
+			}
+		}
+
+		last := 0.0
+		for _, nprobe := range []int{1, 4, 8} {
+			r := recall(exact, searchAll(queries, func(q []float64) []Neighbour { return ivf.Search(q, k, nprobe) }))
+			if r < last {
+				t.Errorf("%s: recall@%d fell from %.3f to %.3f at nprobe=%d", metric, k, last, r, nprobe)
+			}
+			last = r
+		}
+		if last < 0.9 {
+			t.Errorf("%s: recall@%d is %.3f at nprobe=8, want at least 0.9", metric, k, last)
+		}
+	}
+}